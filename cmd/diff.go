@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+// diffCmd compares the planned haproxy config of a loadbalancer with the live one
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "compares the planned haproxy config of a loadbalancer with the live one",
+	Long: `Renders the haproxy config of a loadbalancer the same way the render command does, fetches the
+config haproxy runs from the dataplaneapi and prints the differences section by section.
+
+Exits non-zero when the configs differ, so it can gate rollouts.`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		bindFlags(cmd, renderFlagKeys)
+		bindFlags(cmd, map[string]string{
+			"dataplane.user.name": "dataplane-user-name",
+			"dataplane.user.pwd":  "dataplane-user-pwd",
+			"dataplane.url":       "dataplane-url",
+		})
+
+		return validateRenderFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		planned, err := render(ctx, viper.GetViper())
+		if err != nil {
+			return err
+		}
+
+		live, err := dataplaneapi.NewClient(viper.GetString("dataplane.url"), dataplaneapi.WithLogger(logger)).GetConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		diff, err := manager.DiffConfigs(live, planned)
+		if err != nil {
+			return err
+		}
+
+		if diff == "" {
+			return nil
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), diff)
+
+		// the differences are the result, not a usage error, and Execute reports the error
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		return ErrConfigDiffers
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	addRenderFlags(diffCmd)
+
+	diffCmd.Flags().String("dataplane-user-name", "haproxy", "DataplaneAPI user name")
+	diffCmd.Flags().String("dataplane-user-pwd", "adminpwd", "DataplaneAPI user password")
+	diffCmd.Flags().String("dataplane-url", "http://127.0.0.1:5555/v2/", "DataplaneAPI base url")
+}
@@ -17,6 +17,51 @@ var (
 	// ErrLBIDRequired is the loadbalancer id to watch for changes on the msg queue
 	ErrLBIDRequired = errors.New("loadbalancer-id is required and cannot be empty")
 
+	// ErrHistoryDirRequired is returned when the directory of the history of applied configs is missing
+	ErrHistoryDirRequired = errors.New("history-dir is required and cannot be empty")
+
+	// ErrApplyModeInvalid is returned when the apply mode is unknown
+	ErrApplyModeInvalid = errors.New("apply-mode is invalid, expected one of dataplaneapi or file")
+
+	// ErrDataPlaneCanaryInvalid is returned when the canary is not one of the dataplaneapi urls
+	ErrDataPlaneCanaryInvalid = errors.New("dataplane-canary-url must be one of dataplane-urls")
+
+	// ErrHAProxyReloadInvalid is returned when the haproxy reload method is unknown
+	ErrHAProxyReloadInvalid = errors.New("haproxy-reload is invalid, expected one of master-socket or signal")
+
+	// ErrHAProxyMasterSocketRequired is returned when master socket reloads have no socket
+	ErrHAProxyMasterSocketRequired = errors.New("haproxy-master-socket is required by master-socket reloads")
+
+	// ErrHAProxyPIDFileRequired is returned when signal reloads have no pid file
+	ErrHAProxyPIDFileRequired = errors.New("haproxy-pid-file is required by signal reloads")
+
+	// ErrLBSourceInvalid is returned when the loadbalancer source is unknown
+	ErrLBSourceInvalid = errors.New("loadbalancer-source is invalid, expected one of lbapi, file or http")
+
+	// ErrLBFileRequired is returned when the file source has no file
+	ErrLBFileRequired = errors.New("loadbalancer-file is required by the file loadbalancer-source")
+
+	// ErrLBSourceURLRequired is returned when the http source has no url
+	ErrLBSourceURLRequired = errors.New("loadbalancer-source-url is required by the http loadbalancer-source")
+
+	// ErrLBSourcePollIntervalInvalid is returned when the http source is never polled
+	ErrLBSourcePollIntervalInvalid = errors.New("loadbalancer-source-poll-interval must be positive for the http loadbalancer-source")
+
+	// ErrLBSourceRequired is returned when neither a loadbalancer file nor id is given
+	ErrLBSourceRequired = errors.New("one of loadbalancer-file or loadbalancer-id is required")
+
+	// ErrLBSourceConflict is returned when both a loadbalancer file and id are given
+	ErrLBSourceConflict = errors.New("only one of loadbalancer-file or loadbalancer-id can be given")
+
+	// ErrConfigDiffers is returned when the live haproxy config differs from the planned one
+	ErrConfigDiffers = errors.New("live haproxy config differs from the planned config")
+
 	// ErrLBIDInvalid is returned when the loadbalancer gidx is invalid
 	ErrLBIDInvalid = errors.New("loadbalancer-id (gidx) is invalid")
+
+	// ErrAdminURLRequired is returned when rolling back without a way to pause the manager
+	ErrAdminURLRequired = errors.New("admin-url is required to pause the manager before rolling back, unless manager-stopped is set")
+
+	// ErrAdminTokenRequired is returned when the admin api is enabled without a token
+	ErrAdminTokenRequired = errors.New("admin-token is required to serve the admin api")
 )
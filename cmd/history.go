@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.infratographer.com/x/viperx"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/admin"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/history"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+// historyCmd groups the commands inspecting the history of applied configs
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "inspects and rolls back the history of applied haproxy configs",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		bindFlags(cmd, map[string]string{
+			"history.dir":             "history-dir",
+			"history.limit":           "history-limit",
+			"admin.url":               "admin-url",
+			"admin.token":             "admin-token",
+			"history.manager-stopped": "manager-stopped",
+		})
+		bindFlags(cmd, applyFlagKeys)
+
+		if viper.GetString("history.dir") == "" {
+			return ErrHistoryDirRequired
+		}
+
+		return nil
+	},
+}
+
+// historyListCmd lists the applied configs
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "lists the applied haproxy configs, oldest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := historyStore(viper.GetViper()).List()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(w, "ID\tAPPLIED\tLOADBALANCER\tHASH\tEVENTS")
+
+		for _, e := range entries {
+			events := strings.Join(e.EventIDs, ",")
+			if e.Note != "" {
+				events = e.Note
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%.12s\t%s\n", e.ID, e.Timestamp.Format(time.RFC3339), e.LoadBalancerID, e.Hash, events)
+		}
+
+		return w.Flush()
+	},
+}
+
+// historyDiffCmd shows the differences between two applied configs
+var historyDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "shows the differences between two applied haproxy configs",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := history.ParseID(args[0])
+		if err != nil {
+			return err
+		}
+
+		b, err := history.ParseID(args[1])
+		if err != nil {
+			return err
+		}
+
+		diff, err := historyStore(viper.GetViper()).Diff(a, b)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), diff)
+
+		return nil
+	},
+}
+
+// historyRollbackCmd applies a config from the history again
+var historyRollbackCmd = &cobra.Command{
+	Use:   "rollback <id>",
+	Short: "applies an haproxy config from the history again",
+	Long: `Applies an haproxy config from the history again, the same way the run command applies configs
+with the same apply flags: through the dataplaneapi of one or several haproxy instances, or by
+writing the haproxy config file.
+
+A running manager would reconcile haproxy back to the desired state of the loadbalancer api
+on its next event or resync, so it is paused through its admin api first. It keeps serving
+the rolled back config until applies are resumed with POST /resume on the admin api, once
+the cause is fixed, which reconciles haproxy back to the desired state right away. Without
+an admin api, the rollback is refused unless the manager is stopped.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := history.ParseID(args[0])
+		if err != nil {
+			return err
+		}
+
+		return historyRollback(cmd.Context(), viper.GetViper(), id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historyDiffCmd, historyRollbackCmd)
+
+	historyCmd.PersistentFlags().String("history-dir", "", "Directory the history of applied haproxy configs is kept in")
+	historyCmd.PersistentFlags().Int("history-limit", history.DefaultLimit, "Number of applied haproxy configs kept in the history")
+
+	addApplyFlags(historyRollbackCmd)
+	historyRollbackCmd.Flags().String("admin-url", "", "Admin api url of the running manager, paused before rolling back")
+	historyRollbackCmd.Flags().String("admin-token", "", "Bearer token of the admin api, prefer setting it through the environment")
+	historyRollbackCmd.Flags().Bool("manager-stopped", false, "Roll back without pausing the manager, which must not be running")
+}
+
+// bindFlags binds viper keys to the flags of the command being run, when it has them. The run
+// command binds the same keys to its own flags in init, so the commands sharing them bind theirs
+// when they run, taking over the keys from the run flags.
+func bindFlags(cmd *cobra.Command, keys map[string]string) {
+	for key, name := range keys {
+		if flag := cmd.Flags().Lookup(name); flag != nil {
+			viperx.MustBindFlag(viper.GetViper(), key, flag)
+		}
+	}
+}
+
+// applyFlagKeys are the viper keys of the flags added by addApplyFlags
+var applyFlagKeys = map[string]string{
+	"dataplane.user.name":         "dataplane-user-name",
+	"dataplane.user.pwd":          "dataplane-user-pwd",
+	"dataplane.url":               "dataplane-url",
+	"dataplane.urls":              "dataplane-urls",
+	"dataplane.failure-policy":    "dataplane-failure-policy",
+	"dataplane.canary-url":        "dataplane-canary-url",
+	"dataplane.canary-check-host": "dataplane-canary-check-host",
+	"dataplane.certificates-dir":  "dataplane-certificates-dir",
+	"apply.mode":                  "apply-mode",
+	"haproxy.config.path":         "haproxy-config-path",
+	"haproxy.bin":                 "haproxy-bin",
+	"haproxy.reload":              "haproxy-reload",
+	"haproxy.master-socket":       "haproxy-master-socket",
+	"haproxy.pid-file":            "haproxy-pid-file",
+}
+
+// addApplyFlags adds the flags selecting how configs are applied to cmd
+func addApplyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("dataplane-user-name", "haproxy", "DataplaneAPI user name")
+	cmd.Flags().String("dataplane-user-pwd", "adminpwd", "DataplaneAPI user password")
+	cmd.Flags().String("dataplane-url", "http://127.0.0.1:5555/v2/", "DataplaneAPI base url")
+	cmd.Flags().StringSlice("dataplane-urls", []string{}, "DataplaneAPI base urls of several haproxy instances, configs are checked on one and applied to all. Overrides dataplane-url")
+	cmd.Flags().String("dataplane-failure-policy", string(dataplaneapi.FailurePolicyAll), "Endpoints of dataplane-urls which must apply a config for it to be applied (all, majority, any)")
+	cmd.Flags().String("dataplane-canary-url", "", "One of dataplane-urls configs are applied to first, and rolled to the others once it reloaded and accepts connections on the frontend ports")
+	cmd.Flags().String("dataplane-canary-check-host", "", "Host the frontend ports of the canary are checked on, the host of dataplane-canary-url when empty")
+	cmd.Flags().String("dataplane-certificates-dir", manager.DefaultCertificatesDir, "Directory the DataplaneAPI stores uploaded ssl certificates in")
+	cmd.Flags().String("apply-mode", applyModeDataPlane, "How configs are applied (dataplaneapi, file)")
+	cmd.Flags().String("haproxy-config-path", "/usr/local/etc/haproxy/haproxy.cfg", "haproxy config file written by the file apply mode")
+	cmd.Flags().String("haproxy-bin", "haproxy", "haproxy binary validating configs in the file apply mode")
+	cmd.Flags().String("haproxy-reload", reloadMasterSocket, "How the file apply mode reloads haproxy (master-socket, signal)")
+	cmd.Flags().String("haproxy-master-socket", "", "haproxy master cli socket, for master-socket reloads")
+	cmd.Flags().String("haproxy-pid-file", "", "haproxy master process pid file, for signal reloads")
+}
+
+// historyStore returns the configured history store
+func historyStore(v *viper.Viper) *history.Store {
+	return history.NewStore(v.GetString("history.dir"), history.WithLimit(v.GetInt("history.limit")))
+}
+
+// historyRollback checks and posts a config from the history, and records the rollback in the history
+func historyRollback(ctx context.Context, v *viper.Viper, id uint64) error {
+	if errs := validateApplyFlags(); len(errs) > 0 {
+		return errors.Join(errs...) //nolint:goerr113
+	}
+
+	store := historyStore(v)
+
+	entry, config, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// keep the manager from reconciling the rollback away
+	if !v.GetBool("history.manager-stopped") {
+		if v.GetString("admin.url") == "" {
+			return ErrAdminURLRequired
+		}
+
+		if _, err := admin.NewClient(v.GetString("admin.url"), v.GetString("admin.token")).Pause(ctx); err != nil {
+			return fmt.Errorf("failed to pause the manager: %w", err)
+		}
+
+		logger.Infow("manager paused, resume its applies once the cause is fixed", "adminURL", v.GetString("admin.url"))
+	}
+
+	client, err := newDataPlaneClient(v)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckConfig(ctx, config); err != nil {
+		return err
+	}
+
+	reloadID, err := client.PostConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	if err := client.WaitForReload(ctx, reloadID); err != nil {
+		return err
+	}
+
+	rollback, err := store.Add(history.Entry{
+		LoadBalancerID: entry.LoadBalancerID,
+		Hash:           entry.Hash,
+		Note:           fmt.Sprintf("rollback to %d", entry.ID),
+	}, config)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("config rolled back", "historyID", entry.ID, "rollbackHistoryID", rollback.ID, "loadbalancerID", entry.LoadBalancerID)
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.infratographer.com/x/gidx"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/lbsource"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+// renderCmd renders the haproxy config of a loadbalancer
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "renders the haproxy config of a loadbalancer",
+	Long: `Renders the haproxy config of a loadbalancer, merged into the base config with the local overrides,
+the same way the run command does, and prints it. Neither NATS nor the dataplaneapi are involved.
+
+The loadbalancer is read from a JSON or YAML file shaped like the loadbalancer api responses,
+or fetched once from the loadbalancer api.`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		bindFlags(cmd, renderFlagKeys)
+
+		return validateRenderFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := render(cmd.Context(), viper.GetViper())
+		if err != nil {
+			return err
+		}
+
+		if output, _ := cmd.Flags().GetString("output"); output != "" {
+			return os.WriteFile(output, []byte(config), 0o600)
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), config)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	addRenderFlags(renderCmd)
+	renderCmd.Flags().StringP("output", "o", "", "File to write the haproxy config to instead of stdout")
+}
+
+// renderFlagKeys are the viper keys of the flags added by addRenderFlags
+var renderFlagKeys = map[string]string{
+	"haproxy.config.base":        "base-haproxy-config",
+	"haproxy.config.overrides":   "haproxy-overrides",
+	"haproxy.address-family":     "haproxy-address-family",
+	"dataplane.certificates-dir": "dataplane-certificates-dir",
+	"loadbalancerapi.url":        "loadbalancerapi-url",
+	"loadbalancer.id":            "loadbalancer-id",
+	"loadbalancer.file":          "loadbalancer-file",
+}
+
+// addRenderFlags adds the flags selecting and rendering a loadbalancer to cmd
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().String("base-haproxy-config", "", "Base config for haproxy")
+	cmd.Flags().String("haproxy-overrides", "", "Optional file with local port, pool and origin overrides (routing, tls, health checks, balancing)")
+	cmd.Flags().String("haproxy-address-family", string(manager.AddressFamilyIPv4), "Address family to bind frontend ports on (ipv4, ipv6, dual)")
+	cmd.Flags().String("dataplane-certificates-dir", manager.DefaultCertificatesDir, "Directory the DataplaneAPI stores uploaded ssl certificates in")
+	cmd.Flags().String("loadbalancer-file", "", "JSON or YAML file describing the loadbalancer to render")
+	cmd.Flags().String("loadbalancer-id", "", "Loadbalancer ID to fetch from the LoadbalancerAPI and render")
+	cmd.Flags().String("loadbalancerapi-url", "", "LoadbalancerAPI url")
+}
+
+// render returns the haproxy config of the loadbalancer given by file or fetched from the lb api
+func render(ctx context.Context, v *viper.Viper) (string, error) {
+	addressFamily, err := manager.ParseAddressFamily(v.GetString("haproxy.address-family"))
+	if err != nil {
+		return "", err
+	}
+
+	var lb *lbapi.LoadBalancer
+
+	if path := v.GetString("loadbalancer.file"); path != "" {
+		lb, err = lbsource.LoadFile(path)
+	} else {
+		lb, err = newLBAPIClient(ctx, v.GetString("loadbalancerapi.url")).GetLoadBalancer(ctx, v.GetString("loadbalancer.id"))
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	mgr := &manager.Manager{
+		Logger:          logger,
+		BaseCfgPath:     v.GetString("haproxy.config.base"),
+		OverridesPath:   v.GetString("haproxy.config.overrides"),
+		AddressFamily:   addressFamily,
+		CertificatesDir: v.GetString("dataplane.certificates-dir"),
+	}
+
+	return mgr.Render(lb)
+}
+
+// validateRenderFlags collects the render flag validation
+func validateRenderFlags() error {
+	errs := []error{}
+
+	if viper.GetString("haproxy.config.base") == "" {
+		errs = append(errs, ErrHAProxyBaseConfigRequired)
+	}
+
+	switch file, id := viper.GetString("loadbalancer.file"), viper.GetString("loadbalancer.id"); {
+	case file != "" && id != "":
+		errs = append(errs, ErrLBSourceConflict)
+	case file == "" && id == "":
+		errs = append(errs, ErrLBSourceRequired)
+	case id != "":
+		if _, err := gidx.Parse(id); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrLBIDInvalid, err))
+		}
+
+		if viper.GetString("loadbalancerapi.url") == "" {
+			errs = append(errs, ErrLBAPIURLRequired)
+		}
+	}
+
+	return errors.Join(errs...) //nolint:goerr113
+}
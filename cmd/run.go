@@ -3,23 +3,34 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"go.infratographer.com/x/events"
 	"go.infratographer.com/x/gidx"
+	"go.infratographer.com/x/otelx"
 	"go.infratographer.com/x/viperx"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 
 	"go.infratographer.com/x/oauth2x"
 
 	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
 
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/admin"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/config"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/haproxyfile"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/history"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/lbsource"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/pubsub"
 
 	"github.com/spf13/cobra"
@@ -29,6 +40,29 @@ import (
 const (
 	defaultDataplaneConnRetries       = 30
 	defaultDataplaneConnRetryInterval = 1 * time.Second
+	defaultUpdateDebounce             = 1 * time.Second
+	defaultUpdateMaxWait              = 10 * time.Second
+	defaultMaxConcurrentMsgs          = 100
+	defaultResyncInterval             = 5 * time.Minute
+	httpReadHeaderTimeout             = 5 * time.Second
+	httpShutdownTimeout               = 5 * time.Second
+	tracerShutdownTimeout             = 5 * time.Second
+)
+
+// apply modes and haproxy reload methods
+const (
+	applyModeDataPlane = "dataplaneapi"
+	applyModeFile      = "file"
+
+	reloadMasterSocket = "master-socket"
+	reloadSignal       = "signal"
+)
+
+// load balancer sources
+const (
+	lbSourceAPI  = "lbapi"
+	lbSourceFile = "file"
+	lbSourceHTTP = "http"
 )
 
 // runCmd starts loadbalancer-manager-haproxy service
@@ -55,25 +89,110 @@ func init() {
 	runCmd.PersistentFlags().String("dataplane-url", "http://127.0.0.1:5555/v2/", "DataplaneAPI base url")
 	viperx.MustBindFlag(viper.GetViper(), "dataplane.url", runCmd.PersistentFlags().Lookup("dataplane-url"))
 
+	runCmd.PersistentFlags().StringSlice("dataplane-urls", []string{}, "DataplaneAPI base urls of several haproxy instances, configs are checked on one and applied to all. Overrides dataplane-url")
+	viperx.MustBindFlag(viper.GetViper(), "dataplane.urls", runCmd.PersistentFlags().Lookup("dataplane-urls"))
+
+	runCmd.PersistentFlags().String("dataplane-failure-policy", string(dataplaneapi.FailurePolicyAll), "Endpoints of dataplane-urls which must apply a config for it to be applied (all, majority, any). Failed applies are rolled back and their event messages are nak'd")
+	viperx.MustBindFlag(viper.GetViper(), "dataplane.failure-policy", runCmd.PersistentFlags().Lookup("dataplane-failure-policy"))
+
+	runCmd.PersistentFlags().String("dataplane-canary-url", "", "One of dataplane-urls configs are applied to first, and rolled to the others once it reloaded and accepts connections on the frontend ports")
+	viperx.MustBindFlag(viper.GetViper(), "dataplane.canary-url", runCmd.PersistentFlags().Lookup("dataplane-canary-url"))
+
+	runCmd.PersistentFlags().String("dataplane-canary-check-host", "", "Host the frontend ports of the canary are checked on, the host of dataplane-canary-url when empty")
+	viperx.MustBindFlag(viper.GetViper(), "dataplane.canary-check-host", runCmd.PersistentFlags().Lookup("dataplane-canary-check-host"))
+
 	runCmd.PersistentFlags().Int("dataplane-connect-retries", defaultDataplaneConnRetries, "DataplaneAPI connection retry attempts")
 	viperx.MustBindFlag(viper.GetViper(), "dataplane-connect-retries", runCmd.PersistentFlags().Lookup("dataplane-connect-retries"))
 
 	runCmd.PersistentFlags().Duration("dataplane-connect-retry-interval", defaultDataplaneConnRetryInterval, "DataplaneAPI connection retry interval")
 	viperx.MustBindFlag(viper.GetViper(), "dataplane-connect-retry-interval", runCmd.PersistentFlags().Lookup("dataplane-connect-retry-interval"))
 
+	runCmd.PersistentFlags().String("dataplane-certificates-dir", manager.DefaultCertificatesDir, "Directory the DataplaneAPI stores uploaded ssl certificates in")
+	viperx.MustBindFlag(viper.GetViper(), "dataplane.certificates-dir", runCmd.PersistentFlags().Lookup("dataplane-certificates-dir"))
+
+	runCmd.PersistentFlags().String("apply-mode", applyModeDataPlane, "How configs are applied (dataplaneapi, file). file writes the haproxy config file after validating it with haproxy -c and reloads haproxy")
+	viperx.MustBindFlag(viper.GetViper(), "apply.mode", runCmd.PersistentFlags().Lookup("apply-mode"))
+
+	runCmd.PersistentFlags().String("haproxy-config-path", "/usr/local/etc/haproxy/haproxy.cfg", "haproxy config file written by the file apply mode")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.config.path", runCmd.PersistentFlags().Lookup("haproxy-config-path"))
+
+	runCmd.PersistentFlags().String("haproxy-bin", "haproxy", "haproxy binary validating configs in the file apply mode")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.bin", runCmd.PersistentFlags().Lookup("haproxy-bin"))
+
+	runCmd.PersistentFlags().String("haproxy-reload", reloadMasterSocket, "How the file apply mode reloads haproxy (master-socket, signal). Only master socket reloads of haproxy 2.7 and later are confirmed")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.reload", runCmd.PersistentFlags().Lookup("haproxy-reload"))
+
+	runCmd.PersistentFlags().String("haproxy-master-socket", "", "haproxy master cli socket, for master-socket reloads")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.master-socket", runCmd.PersistentFlags().Lookup("haproxy-master-socket"))
+
+	runCmd.PersistentFlags().String("haproxy-pid-file", "", "haproxy master process pid file, for signal reloads")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.pid-file", runCmd.PersistentFlags().Lookup("haproxy-pid-file"))
+
 	runCmd.PersistentFlags().String("base-haproxy-config", "", "Base config for haproxy")
 	viperx.MustBindFlag(viper.GetViper(), "haproxy.config.base", runCmd.PersistentFlags().Lookup("base-haproxy-config"))
 
+	runCmd.PersistentFlags().String("haproxy-overrides", "", "Optional file with local port, pool and origin overrides (routing, tls, health checks, balancing)")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.config.overrides", runCmd.PersistentFlags().Lookup("haproxy-overrides"))
+
+	runCmd.PersistentFlags().String("haproxy-address-family", string(manager.AddressFamilyIPv4), "Address family to bind frontend ports on (ipv4, ipv6, dual)")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.address-family", runCmd.PersistentFlags().Lookup("haproxy-address-family"))
+
 	runCmd.PersistentFlags().String("loadbalancerapi-url", "", "LoadbalancerAPI url")
 	viperx.MustBindFlag(viper.GetViper(), "loadbalancerapi.url", runCmd.PersistentFlags().Lookup("loadbalancerapi-url"))
 
+	runCmd.PersistentFlags().String("loadbalancer-source", lbSourceAPI, "Source of the loadbalancer (lbapi, file, http). file and http sources do not require change-topics, changes to files are watched and http endpoints are polled")
+	viperx.MustBindFlag(viper.GetViper(), "loadbalancer.source", runCmd.PersistentFlags().Lookup("loadbalancer-source"))
+
+	runCmd.PersistentFlags().String("loadbalancer-file", "", "JSON or YAML file describing the loadbalancer, for the file source")
+	viperx.MustBindFlag(viper.GetViper(), "loadbalancer.file", runCmd.PersistentFlags().Lookup("loadbalancer-file"))
+
+	runCmd.PersistentFlags().String("loadbalancer-source-url", "", "Url the loadbalancer is fetched from as JSON, for the http source. {id} is replaced with the loadbalancer id")
+	viperx.MustBindFlag(viper.GetViper(), "loadbalancer.source-url", runCmd.PersistentFlags().Lookup("loadbalancer-source-url"))
+
+	runCmd.PersistentFlags().Duration("loadbalancer-source-poll-interval", lbsource.DefaultPollInterval, "Interval the http source is polled for changes at")
+	viperx.MustBindFlag(viper.GetViper(), "loadbalancer.source-poll-interval", runCmd.PersistentFlags().Lookup("loadbalancer-source-poll-interval"))
+
 	runCmd.PersistentFlags().String("loadbalancer-id", "", "Loadbalancer ID to act on event changes")
 	viperx.MustBindFlag(viper.GetViper(), "loadbalancer.id", runCmd.PersistentFlags().Lookup("loadbalancer-id"))
 
 	runCmd.PersistentFlags().Uint64("max-msg-process-attempts", 0, "maxiumum number of attempts at processing an event message")
 	viperx.MustBindFlag(viper.GetViper(), "max-msg-process-attempts", runCmd.PersistentFlags().Lookup("max-msg-process-attempts"))
 
+	runCmd.PersistentFlags().Int("max-concurrent-msgs", defaultMaxConcurrentMsgs, "maximum number of event messages processed at the same time, update-debounce only coalesces messages when above 1")
+	viperx.MustBindFlag(viper.GetViper(), "max-concurrent-msgs", runCmd.PersistentFlags().Lookup("max-concurrent-msgs"))
+
+	runCmd.PersistentFlags().Duration("update-debounce", defaultUpdateDebounce, "quiet period without change events before updating the haproxy config, 0 disables debouncing")
+	viperx.MustBindFlag(viper.GetViper(), "update.debounce", runCmd.PersistentFlags().Lookup("update-debounce"))
+
+	runCmd.PersistentFlags().Duration("update-max-wait", defaultUpdateMaxWait, "maximum delay of a debounced haproxy config update")
+	viperx.MustBindFlag(viper.GetViper(), "update.max-wait", runCmd.PersistentFlags().Lookup("update-max-wait"))
+
+	runCmd.PersistentFlags().Duration("resync-interval", defaultResyncInterval, "interval of full resyncs repairing drift of the haproxy config, 0 disables resyncs")
+	viperx.MustBindFlag(viper.GetViper(), "update.resync-interval", runCmd.PersistentFlags().Lookup("resync-interval"))
+
+	runCmd.PersistentFlags().String("state-dir", "", "Directory the last known good haproxy config is persisted to, applied on startup when the LoadbalancerAPI is unavailable")
+	viperx.MustBindFlag(viper.GetViper(), "state-dir", runCmd.PersistentFlags().Lookup("state-dir"))
+
+	runCmd.PersistentFlags().String("frontend-check-host", "", "Host the frontend ports are checked to accept connections on after each reload, the config is rolled back when one does not. Empty disables the check")
+	viperx.MustBindFlag(viper.GetViper(), "haproxy.frontend-check-host", runCmd.PersistentFlags().Lookup("frontend-check-host"))
+
+	runCmd.PersistentFlags().String("history-dir", "", "Directory the history of applied haproxy configs is kept in, empty disables the history")
+	viperx.MustBindFlag(viper.GetViper(), "history.dir", runCmd.PersistentFlags().Lookup("history-dir"))
+
+	runCmd.PersistentFlags().Int("history-limit", history.DefaultLimit, "Number of applied haproxy configs kept in the history")
+	viperx.MustBindFlag(viper.GetViper(), "history.limit", runCmd.PersistentFlags().Lookup("history-limit"))
+
+	runCmd.PersistentFlags().String("http-listen", "", "Address the manager serves /metrics, /healthz and /readyz on, empty disables it")
+	viperx.MustBindFlag(viper.GetViper(), "http.listen", runCmd.PersistentFlags().Lookup("http-listen"))
+
+	runCmd.PersistentFlags().String("admin-listen", "", "Address the manager serves the admin api on, empty disables it")
+	viperx.MustBindFlag(viper.GetViper(), "admin.listen", runCmd.PersistentFlags().Lookup("admin-listen"))
+
+	runCmd.PersistentFlags().String("admin-token", "", "Bearer token authenticating admin api requests, prefer setting it through the environment")
+	viperx.MustBindFlag(viper.GetViper(), "admin.token", runCmd.PersistentFlags().Lookup("admin-token"))
+
 	events.MustViperFlags(viper.GetViper(), runCmd.PersistentFlags(), appName)
+	otelx.MustViperFlags(viper.GetViper(), runCmd.PersistentFlags())
 	oauth2x.MustViperFlags(viper.GetViper(), runCmd.Flags())
 }
 
@@ -92,64 +211,109 @@ func run(cmdCtx context.Context, v *viper.Viper) error {
 		cancel()
 	}()
 
+	// before connecting to events, so published and received messages are traced as well
+	if err := otelx.InitTracer(config.AppConfig.Tracing, appName, logger); err != nil {
+		logger.Fatalw("failed to initialize tracer", "error", err)
+	}
+
+	defer shutdownTracer()
+
 	managedLBID, err := gidx.Parse(viper.GetString("loadbalancer.id"))
 	if err != nil {
 		logger.Fatalw("failed to parse loadbalancer.id gidx: %w", err, "loadbalancerID", viper.GetString("loadbalancer.id"))
 	}
 
+	addressFamily, err := manager.ParseAddressFamily(viper.GetString("haproxy.address-family"))
+	if err != nil {
+		logger.Fatalw("failed to parse haproxy.address-family", "error", err, "addressFamily", viper.GetString("haproxy.address-family"))
+	}
+
 	mgr := &manager.Manager{
 		Context:                       ctx,
 		Logger:                        logger,
-		DataPlaneClient:               dataplaneapi.NewClient(viper.GetString("dataplane.url"), dataplaneapi.WithLogger(logger)),
 		DataPlaneConnectRetries:       viper.GetInt("dataplane-connect-retries"),
 		DataPlaneConnectRetryInterval: viper.GetDuration("dataplane-connect-retry-interval"),
-		LBClient:                      lbapi.NewClient(viper.GetString("loadbalancerapi.url")),
 		ManagedLBID:                   managedLBID,
 		BaseCfgPath:                   viper.GetString("haproxy.config.base"),
+		OverridesPath:                 viper.GetString("haproxy.config.overrides"),
+		AddressFamily:                 addressFamily,
+		CertificatesDir:               viper.GetString("dataplane.certificates-dir"),
+		UpdateDebounce:                viper.GetDuration("update.debounce"),
+		UpdateMaxWait:                 viper.GetDuration("update.max-wait"),
+		ResyncInterval:                viper.GetDuration("update.resync-interval"),
+		StateDir:                      viper.GetString("state-dir"),
+		FrontendCheckHost:             viper.GetString("haproxy.frontend-check-host"),
+	}
+
+	if dir := viper.GetString("history.dir"); dir != "" {
+		mgr.History = history.NewStore(dir, history.WithLimit(viper.GetInt("history.limit")))
 	}
 
 	logger.Infow("Initializing...", zap.String("loadbalancerID", viper.GetString("loadbalancer.id")))
 
-	// init lbapi client
-	if config.AppConfig.OIDC.Client.Issuer != "" {
-		oidcTS, err := oauth2x.NewClientCredentialsTokenSrc(ctx, config.AppConfig.OIDC.Client)
+	// init config applier
+	client, err := newDataPlaneClient(v)
+	if err != nil {
+		logger.Fatalw("failed to create the config applier", "error", err)
+	}
+
+	mgr.DataPlaneClient = client
+
+	// init loadbalancer source
+	switch viper.GetString("loadbalancer.source") {
+	case lbSourceFile:
+		mgr.LBClient = lbsource.NewFile(viper.GetString("loadbalancer.file"))
+	case lbSourceHTTP:
+		mgr.LBClient = lbsource.NewHTTP(viper.GetString("loadbalancer.source-url"),
+			lbsource.WithPollInterval(viper.GetDuration("loadbalancer.source-poll-interval")))
+	default:
+		mgr.LBClient = newLBAPIClient(ctx, viper.GetString("loadbalancerapi.url"))
+	}
+
+	// without change topics, updates rely on the loadbalancer source and resyncs
+	if topics := viper.GetStringSlice("change-topics"); len(topics) > 0 {
+		events, err := events.NewConnection(config.AppConfig.Events, events.WithLogger(logger))
 		if err != nil {
-			logger.Fatalw("failed to create oauth2 token source", "error", err)
+			logger.Fatalw("failed to create events connection", "error", err)
 		}
 
-		oauthHTTPClient := oauth2x.NewClient(ctx, oidcTS)
-		mgr.LBClient = lbapi.NewClient(viper.GetString("loadbalancerapi.url"),
-			lbapi.WithHTTPClient(oauthHTTPClient),
+		defer func() {
+			_ = events.Shutdown(ctx)
+		}()
+
+		// init events subscriber
+		subscriber := pubsub.NewSubscriber(
+			ctx,
+			events,
+			pubsub.WithMsgHandler(mgr.ProcessMsg),
+			pubsub.WithLogger(logger),
+			pubsub.WithMaxMsgProcessAttempts(viper.GetUint64("max-msg-process-attempts")),
+			pubsub.WithMaxConcurrentMsgs(viper.GetInt("max-concurrent-msgs")),
 		)
-	} else {
-		mgr.LBClient = lbapi.NewClient(viper.GetString("loadbalancerapi.url"))
+
+		mgr.Subscriber = subscriber
+
+		for _, topic := range topics {
+			if err := mgr.Subscriber.Subscribe(topic); err != nil {
+				logger.Errorw("failed to subscribe to change topic", zap.String("topic", topic), zap.Error(err))
+				return err
+			}
+		}
 	}
 
-	events, err := events.NewConnection(config.AppConfig.Events, events.WithLogger(logger))
-	if err != nil {
-		logger.Fatalw("failed to create events connection", "error", err)
+	if addr := viper.GetString("http.listen"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.HandleFunc("/healthz", probe(func(_ *http.Request) error { return mgr.Live() }))
+		mux.HandleFunc("/readyz", probe(func(r *http.Request) error { return mgr.Ready(r.Context()) }))
+
+		go serveHTTP(ctx, addr, mux)
 	}
 
-	defer func() {
-		_ = events.Shutdown(ctx)
-	}()
+	if addr := viper.GetString("admin.listen"); addr != "" {
+		server := admin.NewServer(mgr, viper.GetString("admin.token"), admin.WithLogger(logger))
 
-	// init events subscriber
-	subscriber := pubsub.NewSubscriber(
-		ctx,
-		events,
-		pubsub.WithMsgHandler(mgr.ProcessMsg),
-		pubsub.WithLogger(logger),
-		pubsub.WithMaxMsgProcessAttempts(viper.GetUint64("max-msg-process-attempts")),
-	)
-
-	mgr.Subscriber = subscriber
-
-	for _, topic := range viper.GetStringSlice("change-topics") {
-		if err := mgr.Subscriber.Subscribe(topic); err != nil {
-			logger.Errorw("failed to subscribe to change topic", zap.String("topic", topic), zap.Error(err))
-			return err
-		}
+		go serveHTTP(ctx, addr, server.Handler())
 	}
 
 	if err := mgr.Run(); err != nil {
@@ -159,20 +323,109 @@ func run(cmdCtx context.Context, v *viper.Viper) error {
 	return nil
 }
 
+// shutdownTracer exports the spans still buffered by the tracer provider
+func shutdownTracer() {
+	tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	if !ok {
+		// tracing disabled
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+
+	if err := tp.Shutdown(ctx); err != nil {
+		logger.Warnw("failed to shut down tracer provider", "error", err)
+	}
+}
+
+// probe returns a handler answering 200 when check passes, 503 with the error otherwise
+func probe(check func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// serveHTTP serves handler on addr until the context is done
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infow("serving http", "address", addr)
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalw("failed to serve http", "error", err, "address", addr)
+	}
+}
+
+// newLBAPIClient returns a loadbalancer api client, authenticated with oidc client credentials when an issuer is configured
+func newLBAPIClient(ctx context.Context, url string) *lbapi.Client {
+	if config.AppConfig.OIDC.Client.Issuer == "" {
+		return lbapi.NewClient(url)
+	}
+
+	oidcTS, err := oauth2x.NewClientCredentialsTokenSrc(ctx, config.AppConfig.OIDC.Client)
+	if err != nil {
+		logger.Fatalw("failed to create oauth2 token source", "error", err)
+	}
+
+	return lbapi.NewClient(url, lbapi.WithHTTPClient(oauth2x.NewClient(ctx, oidcTS)))
+}
+
 // validateMandatoryFlags collects the mandatory flag validation
 func validateMandatoryFlags() error {
 	errs := []error{}
 
-	if len(viper.GetStringSlice("change-topics")) < 1 {
-		errs = append(errs, ErrSubscriberTopicsRequired)
+	switch viper.GetString("loadbalancer.source") {
+	case lbSourceAPI:
+		if len(viper.GetStringSlice("change-topics")) < 1 {
+			errs = append(errs, ErrSubscriberTopicsRequired)
+		}
+
+		if viper.GetString("loadbalancerapi.url") == "" {
+			errs = append(errs, ErrLBAPIURLRequired)
+		}
+	case lbSourceFile:
+		if viper.GetString("loadbalancer.file") == "" {
+			errs = append(errs, ErrLBFileRequired)
+		}
+	case lbSourceHTTP:
+		if viper.GetString("loadbalancer.source-url") == "" {
+			errs = append(errs, ErrLBSourceURLRequired)
+		}
+
+		if viper.GetDuration("loadbalancer.source-poll-interval") <= 0 {
+			errs = append(errs, ErrLBSourcePollIntervalInvalid)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrLBSourceInvalid, viper.GetString("loadbalancer.source")))
 	}
 
-	if viper.GetString("haproxy.config.base") == "" {
-		errs = append(errs, ErrHAProxyBaseConfigRequired)
+	errs = append(errs, validateApplyFlags()...)
+
+	if viper.GetString("admin.listen") != "" && viper.GetString("admin.token") == "" {
+		errs = append(errs, ErrAdminTokenRequired)
 	}
 
-	if viper.GetString("loadbalancerapi.url") == "" {
-		errs = append(errs, ErrLBAPIURLRequired)
+	if viper.GetString("haproxy.config.base") == "" {
+		errs = append(errs, ErrHAProxyBaseConfigRequired)
 	}
 
 	if viper.GetString("loadbalancer.id") == "" {
@@ -185,3 +438,79 @@ func validateMandatoryFlags() error {
 
 	return errors.Join(errs...) //nolint:goerr113
 }
+
+// validateApplyFlags validates the flags selecting how configs are applied, shared with history rollback
+func validateApplyFlags() []error {
+	errs := []error{}
+
+	switch viper.GetString("apply.mode") {
+	case applyModeDataPlane:
+		if canary := viper.GetString("dataplane.canary-url"); canary != "" && !slices.Contains(viper.GetStringSlice("dataplane.urls"), canary) {
+			errs = append(errs, ErrDataPlaneCanaryInvalid)
+		}
+	case applyModeFile:
+		switch viper.GetString("haproxy.reload") {
+		case reloadMasterSocket:
+			if viper.GetString("haproxy.master-socket") == "" {
+				errs = append(errs, ErrHAProxyMasterSocketRequired)
+			}
+		case reloadSignal:
+			if viper.GetString("haproxy.pid-file") == "" {
+				errs = append(errs, ErrHAProxyPIDFileRequired)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%w: %q", ErrHAProxyReloadInvalid, viper.GetString("haproxy.reload")))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrApplyModeInvalid, viper.GetString("apply.mode")))
+	}
+
+	return errs
+}
+
+// dataPlaneClient applies configs to haproxy
+type dataPlaneClient interface {
+	PostConfig(ctx context.Context, config string) (string, error)
+	WaitForReload(ctx context.Context, reloadID string) error
+	GetConfig(ctx context.Context) (string, error)
+	CheckConfig(ctx context.Context, config string) error
+	UploadCertificate(ctx context.Context, name string, contents []byte) error
+	APIIsReady(ctx context.Context) bool
+	WaitForDataPlaneReady(ctx context.Context, retries int, sleep time.Duration) error
+}
+
+// newDataPlaneClient returns the client applying configs the way apply.mode and the dataplane
+// flags select, shared with history rollback
+func newDataPlaneClient(v *viper.Viper) (dataPlaneClient, error) {
+	if v.GetString("apply.mode") == applyModeFile {
+		opts := []haproxyfile.Option{
+			haproxyfile.WithLogger(logger),
+			haproxyfile.WithHAProxyBin(v.GetString("haproxy.bin")),
+			haproxyfile.WithCertificatesDir(v.GetString("dataplane.certificates-dir")),
+		}
+
+		if v.GetString("haproxy.reload") == reloadSignal {
+			opts = append(opts, haproxyfile.WithPIDFile(v.GetString("haproxy.pid-file")))
+		} else {
+			opts = append(opts, haproxyfile.WithMasterSocket(v.GetString("haproxy.master-socket")))
+		}
+
+		return haproxyfile.NewClient(v.GetString("haproxy.config.path"), opts...), nil
+	}
+
+	if urls := v.GetStringSlice("dataplane.urls"); len(urls) > 0 {
+		policy, err := dataplaneapi.ParseFailurePolicy(v.GetString("dataplane.failure-policy"))
+		if err != nil {
+			return nil, err
+		}
+
+		return dataplaneapi.NewFleet(urls,
+			dataplaneapi.WithFleetLogger(logger),
+			dataplaneapi.WithFailurePolicy(policy),
+			dataplaneapi.WithCanary(v.GetString("dataplane.canary-url")),
+			dataplaneapi.WithCanaryCheckHost(v.GetString("dataplane.canary-check-host")),
+		), nil
+	}
+
+	return dataplaneapi.NewClient(v.GetString("dataplane.url"), dataplaneapi.WithLogger(logger)), nil
+}
@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+// clientTimeout is how long a call to the admin api may take
+var clientTimeout = 10 * time.Second
+
+// Client calls the admin api of a running manager
+type Client struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+// ClientOption configures a client option.
+type ClientOption func(c *Client)
+
+// NewClient returns a client of the admin api served at url, authenticating with a bearer token
+func NewClient(url, token string, options ...ClientOption) *Client {
+	c := &Client{
+		client:  &http.Client{Timeout: clientTimeout},
+		baseURL: strings.TrimSuffix(url, "/"),
+		token:   token,
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithHTTPClient sets the http client used to call the admin api
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// Pause stops the automatic applies of the manager and returns its status
+func (c *Client) Pause(ctx context.Context) (*manager.Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/pause", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	st := &manager.Status{}
+
+	if err := json.NewDecoder(resp.Body).Decode(st); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
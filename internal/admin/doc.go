@@ -0,0 +1,2 @@
+// Package admin provides the authenticated admin http api operators use to control a running manager
+package admin
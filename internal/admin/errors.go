@@ -0,0 +1,14 @@
+package admin
+
+import "errors"
+
+var (
+	// ErrUnauthorized is returned when a request has no valid bearer token
+	ErrUnauthorized = errors.New("missing or invalid bearer token")
+
+	// ErrNoConfigApplied is returned when no config was applied yet
+	ErrNoConfigApplied = errors.New("no config applied yet")
+
+	// ErrUnexpectedStatus is returned when the admin api answers with an unexpected status code
+	ErrUnexpectedStatus = errors.New("unexpected admin api response status")
+)
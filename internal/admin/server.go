@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+// Manager is the manager operated through the admin api
+type Manager interface {
+	Reconcile() error
+	AppliedConfig() (string, string)
+	Status() manager.Status
+	Pause()
+	Resume()
+}
+
+// Server serves the admin api of a manager
+type Server struct {
+	manager Manager
+	token   string
+	logger  *zap.SugaredLogger
+}
+
+// Option configures a server option.
+type Option func(s *Server)
+
+// appliedConfig is the body returned for the applied config
+type appliedConfig struct {
+	Hash   string `json:"hash"`
+	Config string `json:"config"`
+}
+
+// errorResponse is the body returned for a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer returns an admin api server for mgr, authenticating requests with a bearer token
+func NewServer(mgr Manager, token string, options ...Option) *Server {
+	s := &Server{
+		manager: mgr,
+		token:   token,
+		logger:  zap.NewNop().Sugar(),
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithLogger sets the logger for the server
+func WithLogger(logger *zap.SugaredLogger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// Handler returns the http handler of the admin api
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /reconcile", s.reconcile)
+	mux.HandleFunc("GET /config", s.config)
+	mux.HandleFunc("GET /status", s.status)
+	mux.HandleFunc("POST /pause", s.pause)
+	mux.HandleFunc("POST /resume", s.resume)
+
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: ErrUnauthorized.Error()})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reconcile updates the haproxy config right away
+func (s *Server) reconcile(w http.ResponseWriter, _ *http.Request) {
+	s.logger.Infow("reconcile requested through the admin api")
+
+	if err := s.manager.Reconcile(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.manager.Status())
+}
+
+// config returns the applied config and its hash
+func (s *Server) config(w http.ResponseWriter, _ *http.Request) {
+	config, hash := s.manager.AppliedConfig()
+	if config == "" {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: ErrNoConfigApplied.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, appliedConfig{Hash: hash, Config: config})
+}
+
+// status returns the last event, error and apply of the manager
+func (s *Server) status(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.manager.Status())
+}
+
+// pause stops automatic applies
+func (s *Server) pause(w http.ResponseWriter, _ *http.Request) {
+	s.logger.Infow("pause requested through the admin api")
+	s.manager.Pause()
+
+	writeJSON(w, http.StatusOK, s.manager.Status())
+}
+
+// resume restarts automatic applies
+func (s *Server) resume(w http.ResponseWriter, _ *http.Request) {
+	s.logger.Infow("resume requested through the admin api")
+	s.manager.Resume()
+
+	writeJSON(w, http.StatusOK, s.manager.Status())
+}
+
+// writeJSON writes body as the json response
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager"
+)
+
+const testToken = "test-token"
+
+var errReconcile = errors.New("reconcile failed")
+
+// fakeManager records the calls made by the admin api
+type fakeManager struct {
+	config       string
+	hash         string
+	reconcileErr error
+	reconciles   int
+	paused       bool
+}
+
+func (m *fakeManager) Reconcile() error {
+	m.reconciles++
+	return m.reconcileErr
+}
+
+func (m *fakeManager) AppliedConfig() (string, string) {
+	return m.config, m.hash
+}
+
+func (m *fakeManager) Status() manager.Status {
+	return manager.Status{Paused: m.paused, AppliedHash: m.hash}
+}
+
+func (m *fakeManager) Pause() {
+	m.paused = true
+}
+
+func (m *fakeManager) Resume() {
+	m.paused = false
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		manager      *fakeManager
+		expectedCode int
+		expected     func(t *testing.T, mgr *fakeManager, body []byte)
+	}{
+		{
+			name:         "missing token",
+			method:       http.MethodGet,
+			path:         "/status",
+			manager:      &fakeManager{},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid token",
+			method:       http.MethodGet,
+			path:         "/status",
+			token:        "other-token",
+			manager:      &fakeManager{},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "status",
+			method:       http.MethodGet,
+			path:         "/status",
+			token:        testToken,
+			manager:      &fakeManager{hash: "abc"},
+			expectedCode: http.StatusOK,
+			expected: func(t *testing.T, _ *fakeManager, body []byte) {
+				st := manager.Status{}
+				require.NoError(t, json.Unmarshal(body, &st))
+				assert.Equal(t, "abc", st.AppliedHash)
+			},
+		},
+		{
+			name:         "applied config",
+			method:       http.MethodGet,
+			path:         "/config",
+			token:        testToken,
+			manager:      &fakeManager{config: "global\n", hash: "abc"},
+			expectedCode: http.StatusOK,
+			expected: func(t *testing.T, _ *fakeManager, body []byte) {
+				cfg := appliedConfig{}
+				require.NoError(t, json.Unmarshal(body, &cfg))
+				assert.Equal(t, appliedConfig{Hash: "abc", Config: "global\n"}, cfg)
+			},
+		},
+		{
+			name:         "no config applied yet",
+			method:       http.MethodGet,
+			path:         "/config",
+			token:        testToken,
+			manager:      &fakeManager{},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "reconcile",
+			method:       http.MethodPost,
+			path:         "/reconcile",
+			token:        testToken,
+			manager:      &fakeManager{},
+			expectedCode: http.StatusOK,
+			expected: func(t *testing.T, mgr *fakeManager, _ []byte) {
+				assert.Equal(t, 1, mgr.reconciles)
+			},
+		},
+		{
+			name:         "failed reconcile",
+			method:       http.MethodPost,
+			path:         "/reconcile",
+			token:        testToken,
+			manager:      &fakeManager{reconcileErr: errReconcile},
+			expectedCode: http.StatusInternalServerError,
+			expected: func(t *testing.T, _ *fakeManager, body []byte) {
+				assert.Contains(t, string(body), errReconcile.Error())
+			},
+		},
+		{
+			name:         "reconcile requires post",
+			method:       http.MethodGet,
+			path:         "/reconcile",
+			token:        testToken,
+			manager:      &fakeManager{},
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "pause",
+			method:       http.MethodPost,
+			path:         "/pause",
+			token:        testToken,
+			manager:      &fakeManager{},
+			expectedCode: http.StatusOK,
+			expected: func(t *testing.T, mgr *fakeManager, _ []byte) {
+				assert.True(t, mgr.paused)
+			},
+		},
+		{
+			name:         "resume",
+			method:       http.MethodPost,
+			path:         "/resume",
+			token:        testToken,
+			manager:      &fakeManager{paused: true},
+			expectedCode: http.StatusOK,
+			expected: func(t *testing.T, mgr *fakeManager, _ []byte) {
+				assert.False(t, mgr.paused)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+
+			NewServer(tt.manager, testToken).Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+
+			if tt.expected != nil {
+				tt.expected(t, tt.manager, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestClientPause(t *testing.T) {
+	mgr := &fakeManager{hash: "abc"}
+
+	srv := httptest.NewServer(NewServer(mgr, testToken).Handler())
+	defer srv.Close()
+
+	t.Run("pauses the manager", func(t *testing.T) {
+		st, err := NewClient(srv.URL, testToken).Pause(context.Background())
+		require.NoError(t, err)
+
+		assert.True(t, st.Paused)
+		assert.Equal(t, "abc", st.AppliedHash)
+		assert.True(t, mgr.paused)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := NewClient(srv.URL, "other-token").Pause(context.Background())
+		require.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		_, err := NewClient(srv.URL+"/missing", testToken).Pause(context.Background())
+		require.ErrorIs(t, err, ErrUnexpectedStatus)
+	})
+}
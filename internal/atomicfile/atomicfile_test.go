@@ -0,0 +1,29 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "haproxy.cfg")
+
+	require.NoError(t, Write(path, []byte("global\n")))
+	require.NoError(t, Write(path, []byte("global\n  maxconn 10\n")))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "global\n  maxconn 10\n", string(contents))
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.Error(t, Write(filepath.Join(dir, "missing", "haproxy.cfg"), []byte("global\n")))
+}
@@ -0,0 +1,3 @@
+// Package atomicfile writes files through a temporary file renamed in place, so readers such as
+// haproxy never see a partially written file
+package atomicfile
@@ -4,6 +4,7 @@ package config
 import (
 	"go.infratographer.com/x/events"
 	"go.infratographer.com/x/loggingx"
+	"go.infratographer.com/x/otelx"
 
 	"go.infratographer.com/x/oauth2x"
 )
@@ -17,4 +18,5 @@ var AppConfig struct {
 	Events  events.Config
 	Logging loggingx.Config
 	OIDC    OIDCClientConfig
+	Tracing otelx.Config
 }
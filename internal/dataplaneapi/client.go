@@ -3,14 +3,38 @@ package dataplaneapi
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-var dataPlaneClientTimeout = 2 * time.Second
+var (
+	dataPlaneClientTimeout = 2 * time.Second
+
+	// reloadPollInterval is the delay between two checks of the status of a reload
+	reloadPollInterval = 500 * time.Millisecond
+
+	// reloadTimeout is how long a reload may take to complete
+	reloadTimeout = 30 * time.Second
+)
+
+const (
+	reloadStatusSucceeded = "succeeded"
+	reloadStatusFailed    = "failed"
+)
+
+// reload is the status of a haproxy reload triggered by the dataplaneapi
+type reload struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Response string `json:"response"`
+}
 
 // Client is the http client for Data Plane API
 type Client struct {
@@ -26,7 +50,8 @@ type Option func(c *Client)
 func NewClient(url string, options ...Option) *Client {
 	c := &Client{
 		client: &http.Client{
-			Timeout: dataPlaneClientTimeout,
+			Timeout:   dataPlaneClientTimeout,
+			Transport: instrumentedTransport{next: http.DefaultTransport},
 		},
 		baseURL: url,
 		logger:  zap.NewNop().Sugar(),
@@ -93,11 +118,157 @@ func (c Client) CheckConfig(ctx context.Context, config string) error {
 	}
 }
 
-// PostConfig pushes a new haproxy config in plain text using basic auth
-func (c *Client) PostConfig(ctx context.Context, config string) error {
+// PostConfig pushes a new haproxy config in plain text using basic auth, and returns the ID
+// of the reload it triggered, empty when haproxy is not reloaded
+func (c *Client) PostConfig(ctx context.Context, config string) (string, error) {
 	url := c.baseURL + "/services/haproxy/configuration/raw?skip_version=true"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(config))
+	if err != nil {
+		return "", err
+	}
+
+	req.SetBasicAuth(viper.GetString("dataplane.user.name"), viper.GetString("dataplane.user.pwd"))
+	req.Header.Add("Content-Type", "text/plain")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return resp.Header.Get("Reload-ID"), nil
+	case http.StatusUnauthorized:
+		return "", ErrDataPlaneHTTPUnauthorized
+	default:
+		return "", ErrDataPlaneHTTPError
+	}
+}
+
+// WaitForReload polls the status of a reload until it completes, returning an error when it failed
+func (c *Client) WaitForReload(ctx context.Context, reloadID string) error {
+	if reloadID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reloadTimeout)
+	defer cancel()
+
+	for {
+		status, err := c.reloadStatus(ctx, reloadID)
+		if err != nil {
+			return err
+		}
+
+		switch status.Status {
+		case reloadStatusSucceeded:
+			return nil
+		case reloadStatusFailed:
+			return fmt.Errorf("%w %q: %s", ErrDataPlaneReloadFailed, reloadID, status.Response)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w %q", ErrDataPlaneReloadTimeout, reloadID)
+		case <-time.After(reloadPollInterval):
+		}
+	}
+}
+
+// reloadStatus returns the status of a reload
+func (c *Client) reloadStatus(ctx context.Context, reloadID string) (*reload, error) {
+	url := c.baseURL + "/services/haproxy/reloads/" + reloadID
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(viper.GetString("dataplane.user.name"), viper.GetString("dataplane.user.pwd"))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrDataPlaneHTTPUnauthorized
+	default:
+		return nil, ErrDataPlaneHTTPError
+	}
+
+	status := &reload{}
+
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// GetConfig returns the haproxy config currently in use
+func (c *Client) GetConfig(ctx context.Context) (string, error) {
+	url := c.baseURL + "/services/haproxy/configuration/raw"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.SetBasicAuth(viper.GetString("dataplane.user.name"), viper.GetString("dataplane.user.pwd"))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", ErrDataPlaneHTTPUnauthorized
+	default:
+		return "", ErrDataPlaneHTTPError
+	}
+
+	raw := struct {
+		Data string `json:"data"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return "", err
+	}
+
+	return raw.Data, nil
+}
+
+// NormalizeConfig strips the version comment the dataplaneapi adds and surrounding whitespace, so
+// a live config compares equal to the config it was posted from
+func NormalizeConfig(config string) string {
+	lines := strings.Split(strings.TrimSpace(config), "\n")
+
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# _version") {
+		lines = lines[1:]
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// UploadCertificate stores a PEM certificate bundle in the dataplaneapi ssl certificates storage,
+// replacing the existing certificate with the same name. The change is picked up by the next
+// config post instead of reloading haproxy on its own.
+func (c *Client) UploadCertificate(ctx context.Context, name string, contents []byte) error {
+	url := c.baseURL + "/services/haproxy/storage/ssl_certificates/" + name + "?skip_reload=true"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(contents))
 	if err != nil {
 		return err
 	}
@@ -113,7 +284,55 @@ func (c *Client) PostConfig(ctx context.Context, config string) error {
 	defer resp.Body.Close()
 
 	switch resp.StatusCode {
-	case http.StatusAccepted:
+	case http.StatusOK, http.StatusAccepted:
+		return nil
+	case http.StatusNotFound:
+		// not stored yet
+		return c.createCertificate(ctx, name, contents)
+	case http.StatusUnauthorized:
+		return ErrDataPlaneHTTPUnauthorized
+	default:
+		return ErrDataPlaneHTTPError
+	}
+}
+
+// createCertificate uploads a new certificate to the dataplaneapi ssl certificates storage
+func (c *Client) createCertificate(ctx context.Context, name string, contents []byte) error {
+	url := c.baseURL + "/services/haproxy/storage/ssl_certificates"
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+
+	part, err := form.CreateFormFile("file_upload", name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := part.Write(contents); err != nil {
+		return err
+	}
+
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(viper.GetString("dataplane.user.name"), viper.GetString("dataplane.user.pwd"))
+	req.Header.Add("Content-Type", form.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
 		return nil
 	case http.StatusUnauthorized:
 		return ErrDataPlaneHTTPUnauthorized
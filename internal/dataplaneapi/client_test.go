@@ -2,9 +2,11 @@ package dataplaneapi
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,6 +36,7 @@ func TestPostConfig(t *testing.T) {
 
 		return &http.Response{
 			StatusCode: http.StatusAccepted,
+			Header:     http.Header{"Reload-Id": []string{"1-1"}},
 		}
 	})}
 
@@ -42,7 +45,65 @@ func TestPostConfig(t *testing.T) {
 		baseURL: "http://localhost:5555/v2",
 	}
 
-	_ = dc.PostConfig(context.TODO(), "cfg")
+	reloadID, err := dc.PostConfig(context.TODO(), "cfg")
+	require.NoError(t, err)
+	assert.Equal(t, "1-1", reloadID)
+}
+
+func TestWaitForReload(t *testing.T) {
+	reloadPollInterval = time.Millisecond
+
+	tests := []struct {
+		name     string
+		reloadID string
+		statuses []string
+		errMsg   string
+	}{
+		{"no reload", "", nil, ""},
+		{"reload succeeds", "1-1", []string{"in_progress", "in_progress", "succeeded"}, ""},
+		{"reload fails", "1-2", []string{"in_progress", "failed"}, "haproxy reload failed"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // linter
+
+		t.Run(tt.name, func(t *testing.T) {
+			polls := 0
+
+			tc := &http.Client{Transport: RoundTripFunc(func(req *http.Request) *http.Response {
+				_, _, ok := req.BasicAuth()
+				if !ok {
+					t.Error("expected Basic Auth to be set, got", ok)
+				}
+				if !strings.HasSuffix(req.URL.String(), "services/haproxy/reloads/"+tt.reloadID) {
+					t.Error("expected request to end with /services/haproxy/reloads/"+tt.reloadID+", got", req.URL.String())
+				}
+
+				status := tt.statuses[polls]
+				polls++
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(`{"id": "` + tt.reloadID + `", "status": "` + status + `", "response": "[ALERT] cannot bind socket"}`)),
+				}
+			})}
+
+			dc := Client{
+				client:  tc,
+				baseURL: "http://localhost:5555/v2",
+			}
+
+			err := dc.WaitForReload(context.TODO(), tt.reloadID)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.errMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, len(tt.statuses), polls)
+		})
+	}
 }
 
 func TestCheckConfig(t *testing.T) {
@@ -139,3 +200,125 @@ func TestAPIIsReady(t *testing.T) {
 		t.Error("expected dataplane api readiness to be false, got:", ready)
 	}
 }
+
+func TestUploadCertificate(t *testing.T) {
+	tests := []struct {
+		name             string
+		replaceStatus    int
+		createStatus     int
+		expectedRequests []string
+		errMsg           string
+	}{
+		{"replaces existing certificate", http.StatusOK, 0, []string{"PUT"}, ""},
+		{"creates missing certificate", http.StatusNotFound, http.StatusCreated, []string{"PUT", "POST"}, ""},
+		{"create fails", http.StatusNotFound, http.StatusBadRequest, []string{"PUT", "POST"}, "dataplaneapi http error"},
+		{"unauthorized", http.StatusUnauthorized, 0, []string{"PUT"}, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // linter
+
+		t.Run(tt.name, func(t *testing.T) {
+			requests := []string{}
+
+			tc := &http.Client{Transport: RoundTripFunc(func(req *http.Request) *http.Response {
+				_, _, ok := req.BasicAuth()
+				if !ok {
+					t.Error("expected Basic Auth to be set, got", ok)
+				}
+
+				requests = append(requests, req.Method)
+
+				if req.Method == "PUT" {
+					if !strings.Contains(req.URL.String(), "services/haproxy/storage/ssl_certificates/example.pem?skip_reload=true") {
+						t.Error("expected request to contain /services/haproxy/storage/ssl_certificates/example.pem?skip_reload=true, got", req.URL.String())
+					}
+
+					return &http.Response{
+						StatusCode: tt.replaceStatus,
+					}
+				}
+
+				require.NoError(t, req.ParseMultipartForm(1024))
+
+				file, header, err := req.FormFile("file_upload")
+				require.NoError(t, err)
+
+				defer file.Close()
+
+				assert.Equal(t, "example.pem", header.Filename)
+
+				return &http.Response{
+					StatusCode: tt.createStatus,
+				}
+			})}
+
+			dc := Client{
+				client:  tc,
+				baseURL: "http://localhost:5555/v2",
+			}
+
+			err := dc.UploadCertificate(context.TODO(), "example.pem", []byte("pem"))
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.errMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectedRequests, requests)
+		})
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		respStatusCode int
+		respBody       string
+		expectedCfg    string
+		errMsg         string
+	}{
+		{"returns config", http.StatusOK, `{"_version": 3, "data": "# _version=3\nglobal\n"}`, "# _version=3\nglobal\n", ""},
+		{"unauthorized", http.StatusUnauthorized, "", "", "unauthorized"},
+		{"invalid response", http.StatusOK, "global", "", "invalid character"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // linter
+
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &http.Client{Transport: RoundTripFunc(func(req *http.Request) *http.Response {
+				_, _, ok := req.BasicAuth()
+				if !ok {
+					t.Error("expected Basic Auth to be set, got", ok)
+				}
+				if !strings.HasSuffix(req.URL.String(), "services/haproxy/configuration/raw") {
+					t.Error("expected request to end with /services/haproxy/configuration/raw, got", req.URL.String())
+				}
+				if req.Method != "GET" {
+					t.Error("expected request method to be GET, got", req.Method)
+				}
+
+				return &http.Response{
+					StatusCode: tt.respStatusCode,
+					Body:       io.NopCloser(strings.NewReader(tt.respBody)),
+				}
+			})}
+
+			dc := Client{
+				client:  tc,
+				baseURL: "http://localhost:5555/v2",
+			}
+
+			cfg, err := dc.GetConfig(context.TODO())
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.errMsg)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedCfg, cfg)
+			}
+		})
+	}
+}
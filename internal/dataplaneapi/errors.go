@@ -14,4 +14,22 @@ var (
 
 	// ErrDataPlaneConfigInvalid is returned when the config is invalid
 	ErrDataPlaneConfigInvalid = errors.New("dataplaneapi config is invalid")
+
+	// ErrDataPlaneReloadFailed is returned when haproxy failed to reload with a new config
+	ErrDataPlaneReloadFailed = errors.New("dataplaneapi haproxy reload failed")
+
+	// ErrDataPlaneReloadTimeout is returned when a haproxy reload did not complete in time
+	ErrDataPlaneReloadTimeout = errors.New("dataplaneapi haproxy reload timed out")
+
+	// ErrFailurePolicyInvalid is returned when a fleet failure policy is unknown
+	ErrFailurePolicyInvalid = errors.New("dataplaneapi failure policy is invalid")
+
+	// ErrFleetPolicyUnsatisfied is returned when an operation failed on too many endpoints of a fleet
+	ErrFleetPolicyUnsatisfied = errors.New("dataplaneapi fleet failure policy unsatisfied")
+
+	// ErrFleetReloadNotFound is returned when waiting for an unknown fleet reload
+	ErrFleetReloadNotFound = errors.New("dataplaneapi fleet reload not found")
+
+	// ErrCanaryFailed is returned when the canary of a fleet failed to apply a config, which was not rolled to the fleet
+	ErrCanaryFailed = errors.New("dataplaneapi canary failed to apply the config")
 )
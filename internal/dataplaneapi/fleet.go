@@ -0,0 +1,603 @@
+package dataplaneapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// canaryCheckTimeout is how long a frontend port of the canary has to accept a connection
+var canaryCheckTimeout = 2 * time.Second
+
+// FailurePolicy decides whether an operation applied to several dataplaneapi endpoints succeeded
+// when only some of the endpoints succeeded
+type FailurePolicy string
+
+const (
+	// FailurePolicyAll requires every endpoint to succeed
+	FailurePolicyAll FailurePolicy = "all"
+
+	// FailurePolicyMajority requires more than half of the endpoints to succeed
+	FailurePolicyMajority FailurePolicy = "majority"
+
+	// FailurePolicyAny requires a single endpoint to succeed
+	FailurePolicyAny FailurePolicy = "any"
+)
+
+// ParseFailurePolicy returns the FailurePolicy for the given string, an empty string defaults to all
+func ParseFailurePolicy(p string) (FailurePolicy, error) {
+	switch FailurePolicy(p) {
+	case "", FailurePolicyAll:
+		return FailurePolicyAll, nil
+	case FailurePolicyMajority, FailurePolicyAny:
+		return FailurePolicy(p), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrFailurePolicyInvalid, p)
+	}
+}
+
+// satisfied returns true when enough of the endpoints succeeded
+func (p FailurePolicy) satisfied(succeeded, total int) bool {
+	switch p {
+	case FailurePolicyAny:
+		return succeeded > 0
+	case FailurePolicyMajority:
+		return succeeded*2 > total
+	default:
+		return succeeded == total
+	}
+}
+
+// EndpointStatus is the outcome of the config applies to a dataplaneapi endpoint
+type EndpointStatus struct {
+	URL         string    `json:"url"`
+	Applied     uint64    `json:"applied"`
+	Failed      uint64    `json:"failed"`
+	LastApplied time.Time `json:"lastApplied,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// endpoint is a dataplaneapi endpoint of a fleet
+type endpoint struct {
+	client *Client
+	status EndpointStatus
+}
+
+// endpointReload is the reload a config post triggered on an endpoint, or the error posting it
+type endpointReload struct {
+	endpoint *endpoint
+	reloadID string
+	err      error
+
+	// canary reloads are confirmed and recorded before the config is posted to the fleet
+	canary bool
+}
+
+// Fleet applies configs to several haproxy instances through their dataplaneapi. Configs are
+// checked on a single endpoint and applied to all of them, the failure policy deciding whether
+// an apply which failed on some endpoints failed.
+//
+// With a canary, configs are applied to the canary first and rolled to the other endpoints once
+// the canary reloaded and accepts connections on the frontend ports, the canary going back to the
+// last config the fleet applied otherwise.
+type Fleet struct {
+	endpoints  []*endpoint
+	policy     FailurePolicy
+	logger     *zap.SugaredLogger
+	clientOpts []Option
+
+	canaryURL       string
+	canaryCheckHost string
+
+	mu        sync.Mutex
+	posts     map[string]fleetPost
+	postCount uint64
+
+	// applied is the last config the fleet confirmed applied, which every endpoint should run
+	applied string
+}
+
+// fleetPost is a config posted to the fleet, waiting for the reloads of the endpoints
+type fleetPost struct {
+	config  string
+	reloads []endpointReload
+}
+
+// FleetOption configures a fleet setting
+type FleetOption func(f *Fleet)
+
+// WithFailurePolicy sets the failure policy of the fleet
+func WithFailurePolicy(policy FailurePolicy) FleetOption {
+	return func(f *Fleet) {
+		f.policy = policy
+	}
+}
+
+// WithFleetLogger sets the logger for the fleet and its clients
+func WithFleetLogger(logger *zap.SugaredLogger) FleetOption {
+	return func(f *Fleet) {
+		f.logger = logger
+	}
+}
+
+// WithClientOptions sets the options of the client of each endpoint
+func WithClientOptions(opts ...Option) FleetOption {
+	return func(f *Fleet) {
+		f.clientOpts = append(f.clientOpts, opts...)
+	}
+}
+
+// WithCanary applies configs to the endpoint at url first, which must be one of the fleet
+func WithCanary(url string) FleetOption {
+	return func(f *Fleet) {
+		f.canaryURL = url
+	}
+}
+
+// WithCanaryCheckHost sets the host the frontend ports of the canary are checked on, the host of
+// the canary url by default
+func WithCanaryCheckHost(host string) FleetOption {
+	return func(f *Fleet) {
+		f.canaryCheckHost = host
+	}
+}
+
+// NewFleet returns a fleet of the dataplaneapi endpoints at urls
+func NewFleet(urls []string, options ...FleetOption) *Fleet {
+	f := &Fleet{
+		policy: FailurePolicyAll,
+		logger: zap.NewNop().Sugar(),
+		posts:  map[string]fleetPost{},
+	}
+
+	for _, opt := range options {
+		opt(f)
+	}
+
+	for _, url := range urls {
+		opts := append([]Option{WithLogger(f.logger)}, f.clientOpts...)
+
+		f.endpoints = append(f.endpoints, &endpoint{
+			client: NewClient(url, opts...),
+			status: EndpointStatus{URL: url},
+		})
+	}
+
+	return f
+}
+
+// Endpoints returns the status of each endpoint
+func (f *Fleet) Endpoints() []EndpointStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(f.endpoints))
+
+	for _, e := range f.endpoints {
+		statuses = append(statuses, e.status)
+	}
+
+	return statuses
+}
+
+// CheckConfig validates the proposed config on the first endpoint answering
+func (f *Fleet) CheckConfig(ctx context.Context, config string) error {
+	var err error
+
+	for _, e := range f.endpoints {
+		err = e.client.CheckConfig(ctx, config)
+		if err == nil || errors.Is(err, ErrDataPlaneConfigInvalid) {
+			return err
+		}
+
+		f.logger.Warnw("failed to check config on dataplaneapi endpoint", "url", e.status.URL, "error", err)
+	}
+
+	return err
+}
+
+// PostConfig pushes a config to every endpoint and returns the ID of the fleet reload, covering
+// the reloads of each endpoint. It only fails when no endpoint accepted the config, WaitForReload
+// reports the endpoints which did not.
+func (f *Fleet) PostConfig(ctx context.Context, config string) (string, error) {
+	reloads := make([]endpointReload, len(f.endpoints))
+
+	canary := f.canary()
+	if canary != nil {
+		if err := f.applyCanary(ctx, canary, config); err != nil {
+			return "", err
+		}
+	}
+
+	f.each(func(i int, e *endpoint) {
+		if e == canary {
+			reloads[i] = endpointReload{endpoint: e, canary: true}
+			return
+		}
+
+		reloadID, err := e.client.PostConfig(ctx, config)
+		reloads[i] = endpointReload{endpoint: e, reloadID: reloadID, err: err}
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	errs := []error{}
+
+	for _, r := range reloads {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.endpoint.status.URL, r.err))
+		}
+	}
+
+	if len(errs) == len(reloads) {
+		f.record(reloads)
+		return "", errors.Join(errs...)
+	}
+
+	f.postCount++
+	reloadID := "fleet-" + strconv.FormatUint(f.postCount, 10)
+	f.posts[reloadID] = fleetPost{config: config, reloads: reloads}
+
+	return reloadID, nil
+}
+
+// canary returns the canary endpoint, nil without one
+func (f *Fleet) canary() *endpoint {
+	for _, e := range f.endpoints {
+		if f.canaryURL != "" && e.status.URL == f.canaryURL {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// applyCanary applies a config to the canary and checks it, halting the rollout and restoring
+// the last config the fleet applied on the canary when it fails
+func (f *Fleet) applyCanary(ctx context.Context, canary *endpoint, config string) error {
+	err := f.checkCanary(ctx, canary, config)
+
+	f.mu.Lock()
+	applied := f.applied
+	f.recordStatus([]endpointReload{{endpoint: canary, err: err}})
+	f.mu.Unlock()
+
+	if err == nil {
+		f.logger.Infow("canary applied the config, rolling it to the fleet", "url", canary.status.URL)
+		return nil
+	}
+
+	f.logger.Warnw("canary failed to apply the config, halting the rollout", "url", canary.status.URL, "error", err)
+
+	if applied == "" {
+		return fmt.Errorf("%w: %s: %w", ErrCanaryFailed, canary.status.URL, err)
+	}
+
+	restoreErr := f.postAndWait(ctx, canary, applied)
+	if restoreErr != nil {
+		f.logger.Errorw("failed to restore the canary to the last config applied", "url", canary.status.URL, "error", restoreErr)
+
+		return fmt.Errorf("%w: %s: %w: %w", ErrCanaryFailed, canary.status.URL, err, restoreErr)
+	}
+
+	return fmt.Errorf("%w: %s: %w", ErrCanaryFailed, canary.status.URL, err)
+}
+
+// checkCanary applies a config to the canary, and checks it reloaded and accepts connections on the
+// frontend ports bound on every address
+func (f *Fleet) checkCanary(ctx context.Context, canary *endpoint, config string) error {
+	if err := f.postAndWait(ctx, canary, config); err != nil {
+		return err
+	}
+
+	host := f.canaryCheckHost
+	if host == "" {
+		u, err := url.Parse(canary.status.URL)
+		if err != nil {
+			return err
+		}
+
+		host = u.Hostname()
+	}
+
+	for _, port := range frontendPorts(config) {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), canaryCheckTimeout)
+		if err != nil {
+			return fmt.Errorf("frontend port %s: %w", port, err)
+		}
+
+		conn.Close()
+	}
+
+	return nil
+}
+
+// postAndWait posts a config to an endpoint and waits for its reload
+func (f *Fleet) postAndWait(ctx context.Context, e *endpoint, config string) error {
+	reloadID, err := e.client.PostConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	return e.client.WaitForReload(ctx, reloadID)
+}
+
+// frontendPorts returns the ports frontends of a config bind on every address, sorted and unique
+func frontendPorts(config string) []string {
+	ports := []string{}
+	seen := map[string]bool{}
+	frontend := false
+
+	for _, line := range strings.Split(config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		// sections start unindented
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			frontend = fields[0] == "frontend" || fields[0] == "listen"
+			continue
+		}
+
+		if !frontend || fields[0] != "bind" || len(fields) < 2 {
+			continue
+		}
+
+		addr := fields[1]
+		if _, a, ok := strings.Cut(addr, "@"); ok {
+			addr = a
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+
+		switch host {
+		case "", "*", "0.0.0.0", "::":
+		default:
+			// bound to a specific address, such as the local stats frontend
+			continue
+		}
+
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+
+	sort.Strings(ports)
+
+	return ports
+}
+
+// WaitForReload waits for the reloads of a config post on every endpoint, and fails when the
+// endpoints which failed to post or reload the config do not satisfy the failure policy. The
+// config is recorded as applied by the fleet otherwise.
+func (f *Fleet) WaitForReload(ctx context.Context, reloadID string) error {
+	if reloadID == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	post, ok := f.posts[reloadID]
+	delete(f.posts, reloadID)
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w %q", ErrFleetReloadNotFound, reloadID)
+	}
+
+	reloads := post.reloads
+
+	var wg sync.WaitGroup
+
+	for i := range reloads {
+		if reloads[i].err != nil || reloads[i].canary {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(r *endpointReload) {
+			defer wg.Done()
+
+			r.err = r.endpoint.client.WaitForReload(ctx, r.reloadID)
+		}(&reloads[i])
+	}
+
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(reloads); err != nil {
+		return err
+	}
+
+	f.applied = post.config
+
+	return nil
+}
+
+// GetConfig returns the config the endpoints run. When they diverge, the config of an endpoint
+// which does not run the last config applied is returned, so it is seen as drifted.
+func (f *Fleet) GetConfig(ctx context.Context) (string, error) {
+	configs := make([]string, len(f.endpoints))
+	errs := make([]error, len(f.endpoints))
+
+	f.each(func(i int, e *endpoint) {
+		configs[i], errs[i] = e.client.GetConfig(ctx)
+	})
+
+	f.mu.Lock()
+	applied := f.applied
+	f.mu.Unlock()
+
+	live := ""
+
+	for i, config := range configs {
+		if errs[i] != nil {
+			f.logger.Warnw("failed to get config from dataplaneapi endpoint", "url", f.endpoints[i].status.URL, "error", errs[i])
+			continue
+		}
+
+		if live == "" {
+			live = config
+		}
+
+		if applied != "" && NormalizeConfig(config) != NormalizeConfig(applied) {
+			return config, nil
+		}
+	}
+
+	if live == "" {
+		return "", errors.Join(errs...)
+	}
+
+	return live, nil
+}
+
+// UploadCertificate uploads a certificate to every endpoint
+func (f *Fleet) UploadCertificate(ctx context.Context, name string, contents []byte) error {
+	errs := make([]error, len(f.endpoints))
+
+	f.each(func(i int, e *endpoint) {
+		errs[i] = e.client.UploadCertificate(ctx, name, contents)
+	})
+
+	return f.evaluate("upload certificate", errs)
+}
+
+// APIIsReady returns true when enough endpoints to satisfy the failure policy are ready
+func (f *Fleet) APIIsReady(ctx context.Context) bool {
+	ready := make([]bool, len(f.endpoints))
+
+	f.each(func(i int, e *endpoint) {
+		ready[i] = e.client.APIIsReady(ctx)
+	})
+
+	count := 0
+
+	for _, r := range ready {
+		if r {
+			count++
+		}
+	}
+
+	return f.policy.satisfied(count, len(f.endpoints))
+}
+
+// WaitForDataPlaneReady waits for enough endpoints to satisfy the failure policy to be ready
+func (f *Fleet) WaitForDataPlaneReady(ctx context.Context, retries int, sleep time.Duration) error {
+	for i := 0; i < retries; i++ {
+		select {
+		case <-ctx.Done():
+			f.logger.Info("context done")
+			return nil
+		default:
+			if f.APIIsReady(ctx) {
+				f.logger.Info("dataplaneapi fleet is ready")
+				return nil
+			}
+
+			f.logger.Info("waiting for dataplaneapi fleet to become ready")
+			time.Sleep(sleep)
+		}
+	}
+
+	return ErrDataPlaneNotReady
+}
+
+// each runs fn for every endpoint concurrently and waits for them
+func (f *Fleet) each(fn func(i int, e *endpoint)) {
+	var wg sync.WaitGroup
+
+	for i, e := range f.endpoints {
+		wg.Add(1)
+
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+
+			fn(i, e)
+		}(i, e)
+	}
+
+	wg.Wait()
+}
+
+// record updates the status of the endpoints with the outcome of their reloads, and returns
+// an error when the failures do not satisfy the failure policy. f.mu must be held.
+func (f *Fleet) record(reloads []endpointReload) error {
+	errs := make([]error, len(reloads))
+
+	for i, r := range reloads {
+		errs[i] = r.err
+	}
+
+	f.recordStatus(reloads)
+
+	return f.evaluate("apply config", errs)
+}
+
+// recordStatus updates the status of the endpoints with the outcome of their reloads. f.mu must be held.
+func (f *Fleet) recordStatus(reloads []endpointReload) {
+	for _, r := range reloads {
+		if r.canary {
+			continue
+		}
+
+		if r.err != nil {
+			r.endpoint.status.Failed++
+			r.endpoint.status.LastError = r.err.Error()
+
+			continue
+		}
+
+		r.endpoint.status.Applied++
+		r.endpoint.status.LastApplied = time.Now().UTC()
+		r.endpoint.status.LastError = ""
+	}
+}
+
+// evaluate logs the endpoints an operation failed on, and returns an error when the failures
+// do not satisfy the failure policy
+func (f *Fleet) evaluate(operation string, errs []error) error {
+	failures := []error{}
+
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", f.endpoints[i].status.URL, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	err := errors.Join(failures...)
+
+	if !f.policy.satisfied(len(errs)-len(failures), len(errs)) {
+		return fmt.Errorf("%w: %s failed on %d of %d endpoints: %w", ErrFleetPolicyUnsatisfied, operation, len(failures), len(errs), err)
+	}
+
+	f.logger.Warnw("dataplaneapi operation failed on some endpoints, allowed by the failure policy",
+		"operation", operation,
+		"policy", f.policy,
+		"failed", len(failures),
+		"endpoints", len(errs),
+		"error", err)
+
+	return nil
+}
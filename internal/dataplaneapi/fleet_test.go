@@ -0,0 +1,416 @@
+package dataplaneapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDataPlane serves the dataplaneapi endpoints used by a fleet
+type fakeDataPlane struct {
+	mu           sync.Mutex
+	config       string
+	reloadStatus string
+	down         bool
+	checks       int
+	posts        int
+}
+
+func (d *fakeDataPlane) serve(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v2/services/haproxy/configuration/raw" && r.Method == http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+
+			if r.URL.Query().Get("only_validate") == "true" {
+				d.checks++
+
+				if strings.Contains(string(body), "invalid") {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(http.StatusAccepted)
+
+				return
+			}
+
+			d.config = string(body)
+			d.posts++
+
+			reloadID := "1"
+			if strings.Contains(d.config, "fail-reload") {
+				reloadID = "2"
+			}
+
+			w.Header().Set("Reload-ID", reloadID)
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/v2/services/haproxy/configuration/raw":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"_version": 1, "data": "# _version=1\n" + d.config})
+		case r.URL.Path == "/v2/services/haproxy/reloads/1":
+			_ = json.NewEncoder(w).Encode(reload{ID: "1", Status: d.reloadStatus, Response: "reload " + d.reloadStatus})
+		case r.URL.Path == "/v2/services/haproxy/reloads/2":
+			_ = json.NewEncoder(w).Encode(reload{ID: "2", Status: reloadStatusFailed, Response: "reload failed"})
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv.URL + "/v2"
+}
+
+func TestFleetApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      FailurePolicy
+		statuses    []string
+		down        []bool
+		expectedErr error
+	}{
+		{"all succeed", FailurePolicyAll, []string{"succeeded", "succeeded", "succeeded"}, nil, nil},
+		{"one reload fails with all", FailurePolicyAll, []string{"succeeded", "failed", "succeeded"}, nil, ErrFleetPolicyUnsatisfied},
+		{"one reload fails with majority", FailurePolicyMajority, []string{"succeeded", "failed", "succeeded"}, nil, nil},
+		{"two reloads fail with majority", FailurePolicyMajority, []string{"succeeded", "failed", "failed"}, nil, ErrFleetPolicyUnsatisfied},
+		{"one endpoint down with any", FailurePolicyAny, []string{"succeeded", "succeeded", "succeeded"}, []bool{true, true, false}, nil},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			urls := []string{}
+			fakes := []*fakeDataPlane{}
+
+			for i, status := range tt.statuses {
+				fake := &fakeDataPlane{reloadStatus: status}
+				fakes = append(fakes, fake)
+
+				urls = append(urls, fake.serve(t))
+
+				if tt.down != nil {
+					fake.down = tt.down[i]
+				}
+			}
+
+			f := NewFleet(urls, WithFailurePolicy(tt.policy))
+
+			reloadID, err := f.PostConfig(context.Background(), "global\n")
+			require.NoError(t, err)
+
+			err = f.WaitForReload(context.Background(), reloadID)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			for i, s := range f.Endpoints() {
+				failed := tt.statuses[i] == "failed" || (tt.down != nil && tt.down[i])
+
+				assert.Equal(t, urls[i], s.URL)
+
+				if failed {
+					assert.Equal(t, uint64(1), s.Failed)
+					assert.NotEmpty(t, s.LastError)
+				} else {
+					assert.Equal(t, uint64(1), s.Applied)
+					assert.Equal(t, "global\n", fakes[i].config)
+				}
+			}
+		})
+	}
+}
+
+func TestFleetPostConfigAllDown(t *testing.T) {
+	fake := &fakeDataPlane{down: true}
+
+	f := NewFleet([]string{fake.serve(t)})
+
+	_, err := f.PostConfig(context.Background(), "global\n")
+	require.ErrorIs(t, err, ErrDataPlaneHTTPError)
+
+	assert.Equal(t, uint64(1), f.Endpoints()[0].Failed)
+}
+
+func TestFleetAppliedConfig(t *testing.T) {
+	// apply posts a config to the fleet and waits for its reloads
+	apply := func(f *Fleet, config string) error {
+		reloadID, err := f.PostConfig(context.Background(), config)
+		if err != nil {
+			return err
+		}
+
+		return f.WaitForReload(context.Background(), reloadID)
+	}
+
+	t.Run("post failing on every endpoint", func(t *testing.T) {
+		a := &fakeDataPlane{reloadStatus: "succeeded"}
+		b := &fakeDataPlane{reloadStatus: "succeeded"}
+
+		f := NewFleet([]string{a.serve(t), b.serve(t)})
+
+		require.NoError(t, apply(f, "global\n"))
+
+		a.down, b.down = true, true
+		require.ErrorIs(t, apply(f, "global\n  maxconn 10\n"), ErrDataPlaneHTTPError)
+		a.down, b.down = false, false
+
+		// the endpoints run the config applied before, which is no drift
+		live, err := f.GetConfig(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "global", NormalizeConfig(live))
+
+		// an endpoint edited by hand is still reported
+		b.mu.Lock()
+		b.config = "global\n  maxconn 20\n"
+		b.mu.Unlock()
+
+		live, err = f.GetConfig(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, live, "maxconn 20")
+	})
+
+	t.Run("post rejected by the failure policy", func(t *testing.T) {
+		a := &fakeDataPlane{reloadStatus: "succeeded"}
+		b := &fakeDataPlane{reloadStatus: "succeeded"}
+
+		f := NewFleet([]string{a.serve(t), b.serve(t)})
+
+		require.NoError(t, apply(f, "global\n"))
+
+		b.reloadStatus = "failed"
+		require.ErrorIs(t, apply(f, "global\n  maxconn 10\n"), ErrFleetPolicyUnsatisfied)
+
+		// the endpoint running the rejected config is reported as drifted
+		live, err := f.GetConfig(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, live, "maxconn 10")
+	})
+}
+
+func TestFleetCheckConfig(t *testing.T) {
+	down := &fakeDataPlane{down: true}
+	first := &fakeDataPlane{}
+	second := &fakeDataPlane{}
+
+	f := NewFleet([]string{down.serve(t), first.serve(t), second.serve(t)})
+
+	// checked on the first endpoint answering
+	require.NoError(t, f.CheckConfig(context.Background(), "global\n"))
+	require.ErrorIs(t, f.CheckConfig(context.Background(), "invalid\n"), ErrDataPlaneConfigInvalid)
+
+	assert.Equal(t, 2, first.checks)
+	assert.Equal(t, 0, second.checks)
+}
+
+func TestFleetGetConfig(t *testing.T) {
+	a := &fakeDataPlane{reloadStatus: "succeeded"}
+	b := &fakeDataPlane{reloadStatus: "succeeded"}
+
+	f := NewFleet([]string{a.serve(t), b.serve(t)})
+
+	reloadID, err := f.PostConfig(context.Background(), "global\n")
+	require.NoError(t, err)
+	require.NoError(t, f.WaitForReload(context.Background(), reloadID))
+
+	live, err := f.GetConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "global", NormalizeConfig(live))
+
+	// the drifted endpoint is reported
+	b.mu.Lock()
+	b.config = "global\n  maxconn 10\n"
+	b.mu.Unlock()
+
+	live, err = f.GetConfig(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, live, "maxconn 10")
+}
+
+func TestFleetCanary(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	_, openPort, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	// a port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	_, closedPort, err := net.SplitHostPort(closed.Addr().String())
+	require.NoError(t, err)
+	closed.Close()
+
+	good := "frontend a\n  bind ipv4@:" + openPort + "\n"
+
+	tests := []struct {
+		name        string
+		config      string
+		expectedErr error
+	}{
+		{"canary succeeds", "frontend b\n  bind :" + openPort + "\n", nil},
+		{"canary fails to reload", good + "# fail-reload\n", ErrCanaryFailed},
+		{"canary does not accept connections", "frontend b\n  bind ipv4@:" + closedPort + "\n", ErrCanaryFailed},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			canary := &fakeDataPlane{reloadStatus: "succeeded"}
+			other := &fakeDataPlane{reloadStatus: "succeeded"}
+
+			canaryURL := canary.serve(t)
+
+			f := NewFleet([]string{other.serve(t), canaryURL}, WithCanary(canaryURL))
+
+			reloadID, err := f.PostConfig(context.Background(), good)
+			require.NoError(t, err)
+			require.NoError(t, f.WaitForReload(context.Background(), reloadID))
+
+			reloadID, err = f.PostConfig(context.Background(), tt.config)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+
+				// the rollout halted and the canary is back on the last config
+				assert.Equal(t, 1, other.posts)
+				assert.Equal(t, good, other.config)
+				assert.Equal(t, 3, canary.posts)
+				assert.Equal(t, good, canary.config)
+
+				assert.Equal(t, uint64(1), f.Endpoints()[1].Failed)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, f.WaitForReload(context.Background(), reloadID))
+
+			assert.Equal(t, tt.config, other.config)
+			assert.Equal(t, tt.config, canary.config)
+			assert.Equal(t, uint64(2), f.Endpoints()[1].Applied)
+		})
+	}
+}
+
+func TestFleetCanaryAfterFailedRollout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	_, openPort, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	good := "frontend a\n  bind ipv4@:" + openPort + "\n"
+	rejected := "frontend b\n  bind ipv4@:" + openPort + "\n"
+
+	canary := &fakeDataPlane{reloadStatus: "succeeded"}
+	other := &fakeDataPlane{reloadStatus: "succeeded"}
+
+	canaryURL := canary.serve(t)
+
+	f := NewFleet([]string{other.serve(t), canaryURL}, WithCanary(canaryURL))
+
+	reloadID, err := f.PostConfig(context.Background(), good)
+	require.NoError(t, err)
+	require.NoError(t, f.WaitForReload(context.Background(), reloadID))
+
+	// the canary applies the config, which then fails on the rest of the fleet
+	other.mu.Lock()
+	other.reloadStatus = "failed"
+	other.mu.Unlock()
+
+	reloadID, err = f.PostConfig(context.Background(), rejected)
+	require.NoError(t, err)
+	require.ErrorIs(t, f.WaitForReload(context.Background(), reloadID), ErrFleetPolicyUnsatisfied)
+
+	// the canary fails the next config and goes back to the last config the fleet applied
+	_, err = f.PostConfig(context.Background(), good+"# fail-reload\n")
+	require.ErrorIs(t, err, ErrCanaryFailed)
+
+	canary.mu.Lock()
+	defer canary.mu.Unlock()
+
+	assert.Equal(t, good, canary.config)
+}
+
+func TestFrontendPorts(t *testing.T) {
+	config := `global
+  maxconn 100
+
+frontend loadprt-test
+  bind ipv4@:22
+  bind ipv6@:22
+  bind ipv4@:443 ssl crt /etc/haproxy/ssl/site.pem
+
+frontend stats
+  bind 127.0.0.1:29782
+
+listen other
+  bind *:8080
+
+backend loadpol-test
+  server loadogn-test1 1.2.3.4:2222 check port 2222
+`
+
+	assert.Equal(t, []string{"22", "443", "8080"}, frontendPorts(config))
+}
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected FailurePolicy
+		errMsg   string
+	}{
+		{"", FailurePolicyAll, ""},
+		{"all", FailurePolicyAll, ""},
+		{"majority", FailurePolicyMajority, ""},
+		{"any", FailurePolicyAny, ""},
+		{"some", "", "failure policy is invalid"},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := ParseFailurePolicy(tt.input)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package dataplaneapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
+)
+
+// instrumentedTransport observes the duration and status code of dataplaneapi requests
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request and observes it
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+
+	metrics.DataPlaneRequestDuration.WithLabelValues(operation(req), code).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// operation names the dataplaneapi operation of a request
+func operation(req *http.Request) string {
+	path := req.URL.Path
+
+	switch {
+	case strings.HasSuffix(path, "/configuration/raw") && req.Method == http.MethodPost && req.URL.Query().Get("only_validate") == "true":
+		return "check"
+	case strings.HasSuffix(path, "/configuration/raw") && req.Method == http.MethodPost:
+		return "post"
+	case strings.HasSuffix(path, "/configuration/raw"):
+		return "get_config"
+	case strings.Contains(path, "/reloads/"):
+		return "reload_status"
+	case strings.Contains(path, "/storage/ssl_certificates"):
+		return "upload_certificate"
+	default:
+		return "ready"
+	}
+}
@@ -0,0 +1,117 @@
+package dataplaneapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
+)
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		method   string
+		url      string
+		expected string
+	}{
+		{http.MethodPost, "/v2/services/haproxy/configuration/raw?only_validate=true", "check"},
+		{http.MethodPost, "/v2/services/haproxy/configuration/raw?skip_version=true", "post"},
+		{http.MethodGet, "/v2/services/haproxy/configuration/raw", "get_config"},
+		{http.MethodGet, "/v2/services/haproxy/reloads/1-1", "reload_status"},
+		{http.MethodPut, "/v2/services/haproxy/storage/ssl_certificates/site.pem?skip_reload=true", "upload_certificate"},
+		{http.MethodGet, "/v2/", "ready"},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.expected, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, operation(httptest.NewRequest(tt.method, tt.url, nil)))
+		})
+	}
+}
+
+func TestInstrumentedTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("only_validate") == "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Reload-ID", "1-1")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		call      func(c *Client) error
+		operation string
+		code      string
+	}{
+		{
+			name: "post",
+			url:  srv.URL,
+			call: func(c *Client) error {
+				_, err := c.PostConfig(context.Background(), "global\n")
+				return err
+			},
+			operation: "post",
+			code:      "202",
+		},
+		{
+			name: "invalid config",
+			url:  srv.URL,
+			call: func(c *Client) error {
+				return c.CheckConfig(context.Background(), "global\n")
+			},
+			operation: "check",
+			code:      "400",
+		},
+		{
+			name: "no response",
+			url:  closed.URL,
+			call: func(c *Client) error {
+				_, err := c.GetConfig(context.Background())
+				return err
+			},
+			operation: "get_config",
+			code:      "error",
+		},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			observer := metrics.DataPlaneRequestDuration.WithLabelValues(tt.operation, tt.code).(prometheus.Histogram)
+			before := sampleCount(t, observer)
+
+			_ = tt.call(NewClient(tt.url))
+
+			assert.Equal(t, before+1, sampleCount(t, observer))
+		})
+	}
+}
+
+// sampleCount returns the number of observations of a histogram
+func sampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	m := &dto.Metric{}
+	require.NoError(t, h.Write(m))
+
+	return m.GetHistogram().GetSampleCount()
+}
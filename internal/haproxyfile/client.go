@@ -0,0 +1,299 @@
+package haproxyfile
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/atomicfile"
+)
+
+var (
+	// reloadTimeout is how long a reload through the master socket may take to complete
+	reloadTimeout = 30 * time.Second
+
+	// socketDialTimeout is how long connecting to the master socket may take
+	socketDialTimeout = 2 * time.Second
+)
+
+// Client applies configs by writing them to the haproxy config file and reloading haproxy. It stands in
+// for the dataplaneapi client, with reloads confirmed when done through the master socket.
+type Client struct {
+	configPath      string
+	certificatesDir string
+	haproxyBin      string
+	masterSocket    string
+	pidFile         string
+	logger          *zap.SugaredLogger
+
+	mu      sync.Mutex
+	reloads uint64
+	results map[string]error
+}
+
+// Option configures a client setting
+type Option func(c *Client)
+
+// WithLogger sets the logger for the client
+func WithLogger(logger *zap.SugaredLogger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithHAProxyBin sets the haproxy binary validating configs
+func WithHAProxyBin(path string) Option {
+	return func(c *Client) {
+		c.haproxyBin = path
+	}
+}
+
+// WithCertificatesDir sets the directory certificates are written to
+func WithCertificatesDir(dir string) Option {
+	return func(c *Client) {
+		c.certificatesDir = dir
+	}
+}
+
+// WithMasterSocket reloads haproxy with the reload command of its master cli socket, haproxy 2.7
+// and later report whether the reload succeeded
+func WithMasterSocket(path string) Option {
+	return func(c *Client) {
+		c.masterSocket = path
+	}
+}
+
+// WithPIDFile reloads haproxy by sending SIGUSR2 to the master process whose pid is in the file,
+// such reloads cannot be confirmed
+func WithPIDFile(path string) Option {
+	return func(c *Client) {
+		c.pidFile = path
+	}
+}
+
+// NewClient returns a client applying configs to the haproxy config file at configPath
+func NewClient(configPath string, options ...Option) *Client {
+	c := &Client{
+		configPath: configPath,
+		haproxyBin: "haproxy",
+		logger:     zap.NewNop().Sugar(),
+		results:    map[string]error{},
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+// PostConfig writes the config and reloads haproxy, returning the id of the reload
+func (c *Client) PostConfig(ctx context.Context, config string) (string, error) {
+	if err := atomicfile.Write(c.configPath, []byte(config)); err != nil {
+		return "", err
+	}
+
+	err := c.reload(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reloads++
+	reloadID := strconv.FormatUint(c.reloads, 10)
+	c.results[reloadID] = err
+
+	return reloadID, nil
+}
+
+// WaitForReload returns the result of a reload triggered by a config post, reloads are done by the time
+// PostConfig returns
+func (c *Client) WaitForReload(ctx context.Context, reloadID string) error {
+	if reloadID == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err, ok := c.results[reloadID]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrReloadNotFound, reloadID)
+	}
+
+	delete(c.results, reloadID)
+
+	return err
+}
+
+// GetConfig returns the haproxy config file
+func (c *Client) GetConfig(ctx context.Context) (string, error) {
+	config, err := os.ReadFile(c.configPath)
+	if err != nil {
+		return "", err
+	}
+
+	return string(config), nil
+}
+
+// CheckConfig validates the proposed config with haproxy -c without applying it
+func (c *Client) CheckConfig(ctx context.Context, config string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.configPath), ".check-"+filepath.Base(c.configPath)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(config); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	out, err := exec.CommandContext(ctx, c.haproxyBin, "-c", "-f", tmp.Name()).CombinedOutput()
+	if err != nil {
+		c.logger.Debugw("haproxy rejected the config", "output", string(out))
+
+		return fmt.Errorf("%w: %v: %s", ErrConfigInvalid, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// UploadCertificate writes a certificate to the certificates directory
+func (c *Client) UploadCertificate(ctx context.Context, name string, contents []byte) error {
+	if err := os.MkdirAll(c.certificatesDir, 0o750); err != nil {
+		return err
+	}
+
+	return atomicfile.Write(filepath.Join(c.certificatesDir, filepath.Base(name)), contents)
+}
+
+// APIIsReady returns true when haproxy can be reloaded
+func (c *Client) APIIsReady(ctx context.Context) bool {
+	switch {
+	case c.masterSocket != "":
+		conn, err := net.DialTimeout("unix", c.masterSocket, socketDialTimeout)
+		if err != nil {
+			return false
+		}
+
+		conn.Close()
+
+		return true
+	case c.pidFile != "":
+		pid, err := c.masterPID()
+		if err != nil {
+			return false
+		}
+
+		// signal 0 only checks the process exists
+		return syscall.Kill(pid, 0) == nil
+	default:
+		return false
+	}
+}
+
+// WaitForDataPlaneReady waits for haproxy to be ready to reload
+func (c *Client) WaitForDataPlaneReady(ctx context.Context, retries int, sleep time.Duration) error {
+	for i := 0; i < retries; i++ {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("context done")
+			return nil
+		default:
+			if c.APIIsReady(ctx) {
+				c.logger.Info("haproxy is ready")
+				return nil
+			}
+
+			c.logger.Info("waiting for haproxy to become ready")
+			time.Sleep(sleep)
+		}
+	}
+
+	return ErrHAProxyNotReady
+}
+
+// reload reloads haproxy through the master socket, or signals the master process
+func (c *Client) reload(ctx context.Context) error {
+	switch {
+	case c.masterSocket != "":
+		return c.reloadMasterSocket(ctx)
+	case c.pidFile != "":
+		pid, err := c.masterPID()
+		if err != nil {
+			return err
+		}
+
+		return syscall.Kill(pid, syscall.SIGUSR2)
+	default:
+		return ErrReloadNotConfigured
+	}
+}
+
+// reloadMasterSocket sends the reload command to the master socket. haproxy 2.7 and later answer with
+// Success=1 or Success=0 followed by the startup logs, earlier versions close the connection.
+func (c *Client) reloadMasterSocket(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: socketDialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "unix", c.masterSocket)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(reloadTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(conn, "reload\n"); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+
+	status, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if strings.TrimSpace(status) != "Success=0" {
+		return nil
+	}
+
+	logs, _ := io.ReadAll(r)
+
+	return fmt.Errorf("%w: %s", ErrReloadFailed, strings.TrimSpace(strings.TrimPrefix(string(logs), "--")))
+}
+
+// masterPID reads the pid of the master process from the pid file
+func (c *Client) masterPID() (int, error) {
+	contents, err := os.ReadFile(c.pidFile)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrPIDInvalid, c.pidFile)
+	}
+
+	return pid, nil
+}
@@ -0,0 +1,189 @@
+package haproxyfile
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// masterSocket serves the master cli socket of a fake haproxy, answering reloads with response
+func masterSocket(t *testing.T, response string) (string, <-chan string) {
+	path := filepath.Join(t.TempDir(), "master.sock")
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { l.Close() })
+
+	commands := make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			command, _ := bufio.NewReader(conn).ReadString('\n')
+			if command != "" {
+				commands <- command
+				_, _ = io.WriteString(conn, response)
+			}
+
+			conn.Close()
+		}
+	}()
+
+	return path, commands
+}
+
+func TestPostConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		expectedErr error
+	}{
+		{"reload succeeded", "Success=1\n--\n[NOTICE] (1) : haproxy version is 2.8.3\n", nil},
+		{"reload failed", "Success=0\n--\n[ALERT] (1) : config : cannot bind socket\n", ErrReloadFailed},
+		{"unconfirmed reload of haproxy before 2.7", "", nil},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			socket, commands := masterSocket(t, tt.response)
+			configPath := filepath.Join(t.TempDir(), "haproxy.cfg")
+
+			c := NewClient(configPath, WithMasterSocket(socket))
+
+			require.True(t, c.APIIsReady(context.Background()))
+
+			reloadID, err := c.PostConfig(context.Background(), "global\n")
+			require.NoError(t, err)
+
+			assert.Equal(t, "reload\n", <-commands)
+
+			config, err := c.GetConfig(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "global\n", config)
+
+			err = c.WaitForReload(context.Background(), reloadID)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				assert.ErrorContains(t, err, "cannot bind socket")
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			// results are only returned once
+			require.ErrorIs(t, c.WaitForReload(context.Background(), reloadID), ErrReloadNotFound)
+		})
+	}
+
+	t.Run("signals the master process", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR2)
+
+		defer signal.Stop(signals)
+
+		dir := t.TempDir()
+		pidFile := filepath.Join(dir, "haproxy.pid")
+
+		require.NoError(t, os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600))
+
+		c := NewClient(filepath.Join(dir, "haproxy.cfg"), WithPIDFile(pidFile))
+
+		require.True(t, c.APIIsReady(context.Background()))
+
+		reloadID, err := c.PostConfig(context.Background(), "global\n")
+		require.NoError(t, err)
+		require.NoError(t, c.WaitForReload(context.Background(), reloadID))
+
+		select {
+		case <-signals:
+		case <-time.After(time.Second):
+			t.Fatal("expected the master process to be signaled")
+		}
+	})
+
+	t.Run("reload not configured", func(t *testing.T) {
+		c := NewClient(filepath.Join(t.TempDir(), "haproxy.cfg"))
+
+		assert.False(t, c.APIIsReady(context.Background()))
+
+		reloadID, err := c.PostConfig(context.Background(), "global\n")
+		require.NoError(t, err)
+		require.ErrorIs(t, c.WaitForReload(context.Background(), reloadID), ErrReloadNotConfigured)
+	})
+}
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		expectedErr error
+	}{
+		{"valid config", "global\n", nil},
+		{"invalid config", "global\n  invalid\n", ErrConfigInvalid},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			c := NewClient(filepath.Join(dir, "haproxy.cfg"), WithHAProxyBin("testdata/haproxy"))
+
+			err := c.CheckConfig(context.Background(), tt.config)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				assert.ErrorContains(t, err, "unknown keyword")
+			} else {
+				require.NoError(t, err)
+			}
+
+			// the checked config is neither applied nor left behind
+			files, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func TestUploadCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ssl")
+	c := NewClient(filepath.Join(t.TempDir(), "haproxy.cfg"), WithCertificatesDir(dir))
+
+	require.NoError(t, c.UploadCertificate(context.Background(), "site.pem", []byte("cert")))
+
+	contents, err := os.ReadFile(filepath.Join(dir, "site.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, "cert", string(contents))
+}
+
+func TestWaitForDataPlaneReady(t *testing.T) {
+	c := NewClient("haproxy.cfg", WithPIDFile(filepath.Join(t.TempDir(), "missing.pid")))
+
+	err := c.WaitForDataPlaneReady(context.Background(), 2, time.Millisecond)
+	require.ErrorIs(t, err, ErrHAProxyNotReady)
+}
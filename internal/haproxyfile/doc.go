@@ -0,0 +1,3 @@
+// Package haproxyfile applies haproxy configs without the dataplaneapi, writing haproxy.cfg
+// after validating it with haproxy and reloading haproxy through its master socket or a signal
+package haproxyfile
@@ -0,0 +1,23 @@
+package haproxyfile
+
+import "errors"
+
+var (
+	// ErrHAProxyNotReady is returned when haproxy fails to become ready
+	ErrHAProxyNotReady = errors.New("haproxy failed to become ready")
+
+	// ErrConfigInvalid is returned when haproxy rejects a config
+	ErrConfigInvalid = errors.New("haproxy config is invalid")
+
+	// ErrReloadNotConfigured is returned when neither a master socket nor a pid file is configured
+	ErrReloadNotConfigured = errors.New("haproxy reload requires a master socket or a pid file")
+
+	// ErrReloadFailed is returned when haproxy failed to reload with a new config
+	ErrReloadFailed = errors.New("haproxy reload failed")
+
+	// ErrReloadNotFound is returned when waiting for an unknown reload
+	ErrReloadNotFound = errors.New("haproxy reload not found")
+
+	// ErrPIDInvalid is returned when the pid file does not hold a process id
+	ErrPIDInvalid = errors.New("haproxy pid file is invalid")
+)
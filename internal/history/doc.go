@@ -0,0 +1,2 @@
+// Package history provides a bounded on-disk history of the haproxy configs applied by the manager
+package history
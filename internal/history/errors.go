@@ -0,0 +1,11 @@
+package history
+
+import "errors"
+
+var (
+	// ErrEntryNotFound is returned when no history entry has the requested ID
+	ErrEntryNotFound = errors.New("history entry not found")
+
+	// ErrEntryIDInvalid is returned when a history entry ID is not a positive number
+	ErrEntryIDInvalid = errors.New("history entry id is invalid")
+)
@@ -0,0 +1,288 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// DefaultLimit is the number of configs kept by default
+	DefaultLimit = 50
+
+	entrySuffix  = ".json"
+	configSuffix = ".cfg"
+)
+
+// Entry describes a config applied to haproxy
+type Entry struct {
+	ID             uint64    `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	LoadBalancerID string    `json:"loadBalancerID"`
+	Hash           string    `json:"hash"`
+	// EventIDs are the event messages which triggered the update, several when they were coalesced
+	// and none for startup, resyncs and rollbacks
+	EventIDs []string `json:"eventIDs,omitempty"`
+	// Note describes updates not triggered by the lb api, such as rollbacks
+	Note string `json:"note,omitempty"`
+}
+
+// Store is a bounded on-disk history of the configs applied to haproxy. Each config is
+// stored next to its entry, named after its ID, and the oldest ones are pruned past the limit.
+type Store struct {
+	dir   string
+	limit int
+	mu    sync.Mutex
+}
+
+// Option configures a Store setting
+type Option func(s *Store)
+
+// WithLimit sets the number of configs kept, zero or less keeps them all
+func WithLimit(limit int) Option {
+	return func(s *Store) {
+		s.limit = limit
+	}
+}
+
+// NewStore returns a history store in dir
+func NewStore(dir string, opts ...Option) *Store {
+	s := &Store{
+		dir:   dir,
+		limit: DefaultLimit,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Add records an applied config, assigning the entry the next ID, and returns the entry. The
+// manager and the rollback command add entries to the same dir from different processes, so an
+// ID is reserved by creating its config file, moving to the next ID when it already exists.
+func (s *Store) Add(entry Entry, config string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return entry, err
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return entry, err
+	}
+
+	entry.ID = 1
+	if len(ids) > 0 {
+		entry.ID = ids[len(ids)-1] + 1
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	// the config goes first, an entry only ever describes a config fully written
+	for {
+		err := createFile(s.path(entry.ID, configSuffix), []byte(config))
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return entry, err
+		}
+
+		entry.ID++
+	}
+
+	contents, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return entry, err
+	}
+
+	if err := os.WriteFile(s.path(entry.ID, entrySuffix), contents, 0o600); err != nil {
+		return entry, err
+	}
+
+	return entry, s.prune(append(ids, entry.ID))
+}
+
+// List returns the entries, oldest first
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+
+	for _, id := range ids {
+		entry, err := s.entry(id)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Get returns an entry and its config
+func (s *Store) Get(id uint64) (Entry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.entry(id)
+	if err != nil {
+		return entry, "", err
+	}
+
+	config, err := os.ReadFile(s.path(id, configSuffix))
+	if err != nil {
+		return entry, "", err
+	}
+
+	return entry, string(config), nil
+}
+
+// Diff returns the unified diff between the configs of two entries
+func (s *Store) Diff(a, b uint64) (string, error) {
+	entryA, configA, err := s.Get(a)
+	if err != nil {
+		return "", err
+	}
+
+	entryB, configB, err := s.Get(b)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(configA),
+		B:        difflib.SplitLines(configB),
+		FromFile: fmt.Sprintf("%d", entryA.ID),
+		FromDate: entryA.Timestamp.Format(time.RFC3339),
+		ToFile:   fmt.Sprintf("%d", entryB.ID),
+		ToDate:   entryB.Timestamp.Format(time.RFC3339),
+		Context:  3,
+	})
+}
+
+// ParseID parses an entry ID
+func ParseID(id string) (uint64, error) {
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrEntryIDInvalid, id)
+	}
+
+	return parsed, nil
+}
+
+// entry reads the entry with the given ID
+func (s *Store) entry(id uint64) (Entry, error) {
+	entry := Entry{}
+
+	contents, err := os.ReadFile(s.path(id, entrySuffix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entry, fmt.Errorf("%w: %d", ErrEntryNotFound, id)
+		}
+
+		return entry, err
+	}
+
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// ids returns the IDs of the stored entries, sorted
+func (s *Store) ids() ([]uint64, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	ids := []uint64{}
+
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), entrySuffix)
+		if !ok {
+			continue
+		}
+
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	return ids, nil
+}
+
+// prune removes the oldest entries past the limit
+func (s *Store) prune(ids []uint64) error {
+	if s.limit <= 0 || len(ids) <= s.limit {
+		return nil
+	}
+
+	for _, id := range ids[:len(ids)-s.limit] {
+		if err := os.Remove(s.path(id, entrySuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err := os.Remove(s.path(id, configSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createFile writes data to a new file, failing with os.ErrExist when the file already exists
+func createFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// path returns the path of a file of an entry, zero padded so files sort by ID
+func (s *Store) path(id uint64, suffix string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%010d%s", id, suffix))
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("adds, lists and gets entries", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+
+		first, err := store.Add(Entry{LoadBalancerID: "loadbal-test", Hash: "a", EventIDs: []string{"1"}}, "global\n")
+		require.NoError(t, err)
+
+		second, err := store.Add(Entry{LoadBalancerID: "loadbal-test", Hash: "b"}, "global\n  maxconn 10\n")
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(1), first.ID)
+		assert.Equal(t, uint64(2), second.ID)
+		assert.False(t, second.Timestamp.IsZero())
+
+		entries, err := store.List()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, []string{"1"}, entries[0].EventIDs)
+		assert.Equal(t, "b", entries[1].Hash)
+
+		entry, config, err := store.Get(2)
+		require.NoError(t, err)
+
+		assert.Equal(t, "b", entry.Hash)
+		assert.Equal(t, "global\n  maxconn 10\n", config)
+	})
+
+	t.Run("prunes the oldest entries past the limit", func(t *testing.T) {
+		store := NewStore(t.TempDir(), WithLimit(2))
+
+		for _, hash := range []string{"a", "b", "c"} {
+			_, err := store.Add(Entry{Hash: hash}, hash)
+			require.NoError(t, err)
+		}
+
+		entries, err := store.List()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, uint64(2), entries[0].ID)
+		assert.Equal(t, uint64(3), entries[1].ID)
+
+		_, _, err = store.Get(1)
+		require.ErrorIs(t, err, ErrEntryNotFound)
+	})
+
+	t.Run("stores share a dir without overwriting entries", func(t *testing.T) {
+		dir := t.TempDir()
+
+		// the manager and the rollback command each have their own store
+		stores := []*Store{NewStore(dir), NewStore(dir)}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				_, err := stores[i%2].Add(Entry{Hash: strconv.Itoa(i)}, strconv.Itoa(i))
+				assert.NoError(t, err)
+			}(i)
+		}
+
+		wg.Wait()
+
+		entries, err := stores[0].List()
+		require.NoError(t, err)
+		require.Len(t, entries, 20)
+
+		for _, entry := range entries {
+			_, config, err := stores[0].Get(entry.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entry.Hash, config)
+		}
+	})
+
+	t.Run("skips the ID of a config being added", func(t *testing.T) {
+		dir := t.TempDir()
+		store := NewStore(dir)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0000000001.cfg"), []byte("other"), 0o600))
+
+		entry, err := store.Add(Entry{}, "global\n")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), entry.ID)
+
+		other, err := os.ReadFile(filepath.Join(dir, "0000000001.cfg"))
+		require.NoError(t, err)
+		assert.Equal(t, "other", string(other))
+	})
+
+	t.Run("lists an empty history", func(t *testing.T) {
+		entries, err := NewStore(t.TempDir() + "/missing").List()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("diffs two entries", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+
+		_, err := store.Add(Entry{}, "global\n  maxconn 10\n")
+		require.NoError(t, err)
+
+		_, err = store.Add(Entry{}, "global\n  maxconn 20\n")
+		require.NoError(t, err)
+
+		diff, err := store.Diff(1, 2)
+		require.NoError(t, err)
+
+		assert.Contains(t, diff, "-  maxconn 10\n")
+		assert.Contains(t, diff, "+  maxconn 20\n")
+
+		_, err = store.Diff(1, 3)
+		require.ErrorIs(t, err, ErrEntryNotFound)
+	})
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("12")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), id)
+
+	_, err = ParseID("latest")
+	require.ErrorIs(t, err, ErrEntryIDInvalid)
+}
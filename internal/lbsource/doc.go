@@ -0,0 +1,3 @@
+// Package lbsource provides load balancers described outside of the loadbalancer api, read from
+// local files or fetched from plain http endpoints
+package lbsource
@@ -0,0 +1,20 @@
+package lbsource
+
+import "errors"
+
+var (
+	// ErrLoadBalancerInvalid is returned when a load balancer cannot be decoded
+	ErrLoadBalancerInvalid = errors.New("load balancer is invalid")
+
+	// ErrLoadBalancerNotFound is returned when a source does not describe the requested load balancer
+	ErrLoadBalancerNotFound = errors.New("load balancer not found")
+
+	// ErrHTTPStatus is returned when an http source responds with an unexpected status
+	ErrHTTPStatus = errors.New("unexpected load balancer source response status")
+
+	// ErrLoadBalancerIDRequired is returned when a load balancer has no ID
+	ErrLoadBalancerIDRequired = errors.New("load balancer id is required")
+
+	// ErrPollIntervalInvalid is returned when an http source is watched without a positive poll interval
+	ErrPollIntervalInvalid = errors.New("poll interval must be positive")
+)
@@ -0,0 +1,117 @@
+package lbsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+)
+
+// File is a load balancer source reading a JSON or YAML file, read again on every request
+type File struct {
+	path string
+}
+
+// NewFile returns a source reading the load balancer from the file at path
+func NewFile(path string) *File {
+	return &File{path: filepath.Clean(path)}
+}
+
+// GetLoadBalancer reads the load balancer from the file, which must describe the load balancer with the given id
+func (f *File) GetLoadBalancer(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+	lb, err := LoadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if lb.ID != id {
+		return nil, fmt.Errorf("%w: %s describes %q", ErrLoadBalancerNotFound, f.path, lb.ID)
+	}
+
+	return lb, nil
+}
+
+// Watch calls changed whenever the file is written, created or replaced, until the context is done.
+// The directory is watched rather than the file, so files replaced by renames keep being watched.
+func (f *File) Watch(ctx context.Context, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if filepath.Clean(event.Name) == f.path && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				changed()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// LoadFile reads a load balancer from a JSON or YAML file
+func LoadFile(path string) (*lbapi.LoadBalancer, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lb, err := Parse(contents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return lb, nil
+}
+
+// Parse decodes a load balancer from JSON or YAML, shaped like the loadbalancer api responses
+// (ports.edges[].node.pools[].origins.edges[].node)
+func Parse(contents []byte) (*lbapi.LoadBalancer, error) {
+	// JSON is YAML, decode generically first and convert to the JSON the api client types expect
+	var data interface{}
+
+	if err := yaml.Unmarshal(contents, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLoadBalancerInvalid, err)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLoadBalancerInvalid, err)
+	}
+
+	lb := &lbapi.LoadBalancer{}
+
+	if err := json.Unmarshal(encoded, lb); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLoadBalancerInvalid, err)
+	}
+
+	if lb.ID == "" {
+		return nil, ErrLoadBalancerIDRequired
+	}
+
+	return lb, nil
+}
@@ -0,0 +1,164 @@
+package lbsource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+)
+
+func TestLoadFile(t *testing.T) {
+	expected := &lbapi.LoadBalancer{
+		ID:   "loadbal-test",
+		Name: "test",
+		Ports: lbapi.Ports{
+			Edges: []lbapi.PortEdges{
+				{
+					Node: lbapi.PortNode{
+						ID:     "loadprt-test",
+						Name:   "ssh-service",
+						Number: 22,
+						Pools: []lbapi.Pool{
+							{
+								ID:       "loadpol-test",
+								Name:     "ssh-service-a",
+								Protocol: "tcp",
+								Origins: lbapi.Origins{
+									Edges: []lbapi.OriginEdges{
+										{
+											Node: lbapi.OriginNode{
+												ID:         "loadogn-test1",
+												Name:       "svr1-2222",
+												Target:     "1.2.3.4",
+												PortNumber: 2222,
+												Weight:     10,
+												Active:     true,
+											},
+										},
+										{
+											Node: lbapi.OriginNode{
+												ID:         "loadogn-test2",
+												Name:       "svr1-222",
+												Target:     "1.2.3.4",
+												PortNumber: 222,
+												Weight:     20,
+												Active:     false,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"yaml", "testdata/lb.yaml"},
+		{"json", "testdata/lb.json"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			lb, err := LoadFile(tt.path)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, lb)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		expectedErr error
+	}{
+		{"not yaml", "id: [", ErrLoadBalancerInvalid},
+		{"wrong shape", "ports: [1, 2]", ErrLoadBalancerInvalid},
+		{"missing id", "name: test", ErrLoadBalancerIDRequired},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse([]byte(tt.contents))
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Run("gets the load balancer", func(t *testing.T) {
+		lb, err := NewFile("testdata/lb.yaml").GetLoadBalancer(context.Background(), "loadbal-test")
+		require.NoError(t, err)
+
+		assert.Equal(t, "loadbal-test", lb.ID)
+	})
+
+	t.Run("another load balancer is not found", func(t *testing.T) {
+		_, err := NewFile("testdata/lb.yaml").GetLoadBalancer(context.Background(), "loadbal-other")
+		require.ErrorIs(t, err, ErrLoadBalancerNotFound)
+	})
+
+	t.Run("watches writes and replacements", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "lb.yaml")
+
+		require.NoError(t, os.WriteFile(path, []byte("id: loadbal-test\n"), 0o600))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		changes := make(chan struct{}, 10)
+		watching := make(chan error, 1)
+
+		go func() {
+			watching <- NewFile(path).Watch(ctx, func() { changes <- struct{}{} })
+		}()
+
+		// writes to other files are ignored
+		assert.Eventually(t, func() bool {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("id: other\n"), 0o600))
+			require.NoError(t, os.WriteFile(path, []byte("id: loadbal-test\nname: a\n"), 0o600))
+
+			return len(changes) > 0
+		}, time.Second, 10*time.Millisecond)
+
+		for len(changes) > 0 {
+			<-changes
+		}
+
+		tmp := filepath.Join(dir, ".lb.yaml.tmp")
+		require.NoError(t, os.WriteFile(tmp, []byte("id: loadbal-test\nname: b\n"), 0o600))
+		require.NoError(t, os.Rename(tmp, path))
+
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("replacing the file was not reported")
+		}
+
+		cancel()
+
+		require.ErrorIs(t, <-watching, context.Canceled)
+	})
+}
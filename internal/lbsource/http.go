@@ -0,0 +1,166 @@
+package lbsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+)
+
+var (
+	httpClientTimeout = 10 * time.Second
+
+	// DefaultPollInterval is how often http sources are polled by default
+	DefaultPollInterval = 30 * time.Second
+)
+
+// HTTP is a load balancer source fetching JSON load balancers from a plain http endpoint
+type HTTP struct {
+	client       *http.Client
+	url          string
+	pollInterval time.Duration
+
+	// lastURL and lastSum are the url and the body checksum of the last load balancer fetched
+	mu      sync.Mutex
+	lastURL string
+	lastSum [sha256.Size]byte
+}
+
+// HTTPOption configures an http source setting
+type HTTPOption func(h *HTTP)
+
+// WithHTTPClient sets the http client fetching the load balancers
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(h *HTTP) {
+		h.client = client
+	}
+}
+
+// WithPollInterval sets how often Watch polls the load balancer for changes
+func WithPollInterval(interval time.Duration) HTTPOption {
+	return func(h *HTTP) {
+		h.pollInterval = interval
+	}
+}
+
+// NewHTTP returns a source fetching load balancers from url, where {id} is replaced with the
+// requested load balancer id
+func NewHTTP(url string, opts ...HTTPOption) *HTTP {
+	h := &HTTP{
+		client: &http.Client{
+			Timeout: httpClientTimeout,
+		},
+		url:          url,
+		pollInterval: DefaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Watch polls the load balancer last fetched every poll interval, and calls changed when it differs
+// from the one fetched last, until the context is done
+func (h *HTTP) Watch(ctx context.Context, changed func()) error {
+	if h.pollInterval <= 0 {
+		return ErrPollIntervalInvalid
+	}
+
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			h.mu.Lock()
+			endpoint, sum := h.lastURL, h.lastSum
+			h.mu.Unlock()
+
+			if endpoint == "" {
+				// nothing fetched yet
+				continue
+			}
+
+			// failures are reported by the fetch of the update instead
+			contents, err := h.fetch(ctx, endpoint)
+			if err != nil || sha256.Sum256(contents) == sum {
+				continue
+			}
+
+			// report each change once, until the update fetches it
+			h.mu.Lock()
+			h.lastSum = sha256.Sum256(contents)
+			h.mu.Unlock()
+
+			changed()
+		}
+	}
+}
+
+// GetLoadBalancer fetches the load balancer with the given id
+func (h *HTTP) GetLoadBalancer(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+	endpoint := strings.ReplaceAll(h.url, "{id}", url.PathEscape(id))
+
+	contents, err := h.fetch(ctx, endpoint)
+	if err != nil {
+		if errors.Is(err, ErrLoadBalancerNotFound) {
+			return nil, fmt.Errorf("%w: %q", ErrLoadBalancerNotFound, id)
+		}
+
+		return nil, err
+	}
+
+	h.mu.Lock()
+	h.lastURL, h.lastSum = endpoint, sha256.Sum256(contents)
+	h.mu.Unlock()
+
+	lb, err := Parse(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	if lb.ID != id {
+		return nil, fmt.Errorf("%w: %s returned %q", ErrLoadBalancerNotFound, endpoint, lb.ID)
+	}
+
+	return lb, nil
+}
+
+// fetch returns the body of a GET request to endpoint
+func (h *HTTP) fetch(ctx context.Context, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrLoadBalancerNotFound
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrHTTPStatus, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,121 @@
+package lbsource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP(t *testing.T) {
+	contents, err := os.ReadFile("testdata/lb.json")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loadbalancers/loadbal-test":
+			_, _ = w.Write(contents)
+		case "/loadbalancers/loadbal-broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		id          string
+		expectedErr error
+	}{
+		{"gets the load balancer", srv.URL + "/loadbalancers/{id}", "loadbal-test", nil},
+		{"url without id", srv.URL + "/loadbalancers/loadbal-test", "loadbal-test", nil},
+		{"another load balancer is not found", srv.URL + "/loadbalancers/loadbal-test", "loadbal-other", ErrLoadBalancerNotFound},
+		{"missing load balancer", srv.URL + "/loadbalancers/{id}", "loadbal-other", ErrLoadBalancerNotFound},
+		{"server error", srv.URL + "/loadbalancers/{id}", "loadbal-broken", ErrHTTPStatus},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			lb, err := NewHTTP(tt.url).GetLoadBalancer(context.Background(), tt.id)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.id, lb.ID)
+			assert.Len(t, lb.Ports.Edges, 1)
+		})
+	}
+}
+
+func TestHTTPWatch(t *testing.T) {
+	contents, err := os.ReadFile("testdata/lb.json")
+	require.NoError(t, err)
+
+	var body atomic.Pointer[[]byte]
+
+	body.Store(&contents)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(*body.Load())
+	}))
+	defer srv.Close()
+
+	t.Run("reports changes of the load balancer", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source := NewHTTP(srv.URL+"/loadbalancers/{id}", WithPollInterval(10*time.Millisecond))
+
+		changes := make(chan struct{}, 10)
+		watched := make(chan error, 1)
+
+		go func() {
+			watched <- source.Watch(ctx, func() {
+				changes <- struct{}{}
+			})
+		}()
+
+		_, err := source.GetLoadBalancer(ctx, "loadbal-test")
+		require.NoError(t, err)
+
+		// unchanged
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, changes)
+
+		changed := append([]byte{}, contents...)
+		changed = append(changed, '\n')
+
+		body.Store(&changed)
+
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("change not reported")
+		}
+
+		// reported once
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, changes)
+
+		cancel()
+
+		require.ErrorIs(t, <-watched, context.Canceled)
+	})
+
+	t.Run("requires a poll interval", func(t *testing.T) {
+		err := NewHTTP(srv.URL, WithPollInterval(0)).Watch(context.Background(), func() {})
+		require.ErrorIs(t, err, ErrPollIntervalInvalid)
+	})
+}
@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+	"go.uber.org/zap"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
+)
+
+// frontendCheckTimeout is how long a frontend port has to accept a connection after a reload
+var frontendCheckTimeout = 2 * time.Second
+
+// confirmApply waits for the reload triggered by a config post to succeed, then checks the
+// frontend of every port accepts connections
+func (m *Manager) confirmApply(reloadID string, lb *lbapi.LoadBalancer) error {
+	if err := m.DataPlaneClient.WaitForReload(m.Context, reloadID); err != nil {
+		return err
+	}
+
+	if m.FrontendCheckHost == "" {
+		return nil
+	}
+
+	for _, p := range lb.Ports.Edges {
+		addr := net.JoinHostPort(m.FrontendCheckHost, strconv.FormatInt(p.Node.Number, 10))
+
+		conn, err := net.DialTimeout("tcp", addr, frontendCheckTimeout)
+		if err != nil {
+			return newLabelError(p.Node.ID, errFrontendCheckFailure, err)
+		}
+
+		conn.Close()
+	}
+
+	return nil
+}
+
+// rollback posts the last applied config again after an apply failed, and returns the apply failure
+func (m *Manager) rollback(cause error) error {
+	applyErr := fmt.Errorf("%w: %v", errApplyFailed, cause)
+
+	current, _ := m.AppliedConfig()
+	if current == "" {
+		m.Logger.Errorw("config apply failed, no previous config to roll back to",
+			zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(cause))
+
+		return applyErr
+	}
+
+	reloadID, err := m.DataPlaneClient.PostConfig(m.Context, current)
+	if err == nil {
+		err = m.DataPlaneClient.WaitForReload(m.Context, reloadID)
+	}
+
+	if err != nil {
+		m.Logger.Errorw("config apply failed and rolling back failed",
+			zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(cause), zap.NamedError("rollbackError", err))
+
+		return fmt.Errorf("%w: %v: %w: %v", errApplyFailed, cause, errRollbackFailed, err)
+	}
+
+	rollbacks := atomic.AddUint64(&m.rollbacks, 1)
+	metrics.RollbacksTotal.Inc()
+
+	m.Logger.Warnw("config apply failed, rolled back to the last applied config",
+		zap.String("loadbalancerID", m.ManagedLBID.String()),
+		zap.Uint64("rollbacks", rollbacks),
+		zap.Error(cause))
+
+	return applyErr
+}
+
+// Rollbacks returns the number of applies which failed and were rolled back
+func (m *Manager) Rollbacks() uint64 {
+	return atomic.LoadUint64(&m.rollbacks)
+}
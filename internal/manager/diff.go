@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+)
+
+// configSection is a section of an haproxy config, such as a frontend or a backend
+type configSection struct {
+	header string
+	lines  []string
+}
+
+// DiffConfigs returns a unified diff of the live and planned haproxy configs, section by section.
+// Indentation, blank lines and comments are ignored, and an empty diff means the configs are equivalent.
+func DiffConfigs(live, planned string) (string, error) {
+	liveSections := splitSections(live)
+	plannedSections := splitSections(planned)
+
+	headers := []string{}
+	seen := map[string]bool{}
+
+	for _, s := range append(plannedSections, liveSections...) {
+		if !seen[s.header] {
+			seen[s.header] = true
+			headers = append(headers, s.header)
+		}
+	}
+
+	var diff strings.Builder
+
+	for _, header := range headers {
+		a := sectionLines(liveSections, header)
+		b := sectionLines(plannedSections, header)
+
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        a,
+			B:        b,
+			FromFile: "live " + header,
+			ToFile:   "planned " + header,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		diff.WriteString(d)
+	}
+
+	return diff.String(), nil
+}
+
+// splitSections splits a config into its sections, a section starting at each unindented line
+// which is kept as its first line, so sections added or removed show in full
+func splitSections(config string) []configSection {
+	sections := []configSection{}
+
+	for _, line := range strings.Split(dataplaneapi.NormalizeConfig(config), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if trimmed == line || len(sections) == 0 {
+			sections = append(sections, configSection{header: trimmed, lines: []string{trimmed + "\n"}})
+			continue
+		}
+
+		sections[len(sections)-1].lines = append(sections[len(sections)-1].lines, trimmed+"\n")
+	}
+
+	return sections
+}
+
+// sectionLines returns the lines of the section with the given header, none when the config lacks it
+func sectionLines(sections []configSection, header string) []string {
+	for _, s := range sections {
+		if s.header == header {
+			return s.lines
+		}
+	}
+
+	return nil
+}
@@ -9,6 +9,30 @@ var (
 	// errLoadBalancerIDParamInvalid is returned when an invalid load balancer ID is provided
 	errLoadBalancerIDParamInvalid = errors.New("loadbalancer ID is empty")
 
+	// errBaseConfigInvalid is returned when the base haproxy config cannot be loaded
+	errBaseConfigInvalid = errors.New("failed to load haproxy base config")
+
+	// errLoadBalancerUnavailable is returned when the load balancer cannot be fetched from the lb api
+	errLoadBalancerUnavailable = errors.New("failed to get loadbalancer from lb api")
+
+	// errLoadBalancerNotFound is returned when the lb api or source does not know the load balancer
+	errLoadBalancerNotFound = errors.New("loadbalancer not found in lb api")
+
+	// errApplyFailed is returned when haproxy failed to reload with a config or its frontends are not reachable
+	errApplyFailed = errors.New("failed to apply haproxy config")
+
+	// errRollbackFailed is returned when the last applied config cannot be posted again after a failed apply
+	errRollbackFailed = errors.New("failed to roll back haproxy config")
+
+	// errFrontendCheckFailure is returned when a frontend does not accept connections after a reload
+	errFrontendCheckFailure = errors.New("frontend is not accepting connections")
+
+	// errLastKnownGoodUnavailable is returned when no last known good config can be applied
+	errLastKnownGoodUnavailable = errors.New("last known good config is unavailable")
+
+	// errLastKnownGoodMismatch is returned when the persisted config belongs to another load balancer
+	errLastKnownGoodMismatch = errors.New("persisted config is for another loadbalancer")
+
 	// errFrontendSectionLabelFailure is returned when a frontend section cannot be created
 	errFrontendSectionLabelFailure = errors.New("failed to create frontend section with label")
 
@@ -21,8 +45,98 @@ var (
 	// errBackendSectionLabelFailure is returned when a backend section cannot be created
 	errBackendSectionLabelFailure = errors.New("failed to create section backend with label")
 
+	// errAddressFamilyInvalid is returned when an unsupported address family is provided
+	errAddressFamilyInvalid = errors.New("address family is invalid")
+
 	// errBackendServerFailure is returned when a server cannot be applied to a backend
 	errBackendServerFailure = errors.New("failed to add backend attr server: ")
+
+	// errOriginTargetInvalid is returned when an origin target cannot be rendered as a server address
+	errOriginTargetInvalid = errors.New("invalid origin target")
+
+	// errOriginTargetUnsupported is returned when an origin target is neither an IP literal nor a hostname
+	errOriginTargetUnsupported = errors.New("target is not an IPv4, IPv6 or FQDN address")
+
+	// errOverridesInvalid is returned when the overrides file cannot be parsed
+	errOverridesInvalid = errors.New("invalid overrides file")
+
+	// errHealthCheckTypeInvalid is returned when an unsupported health check type is configured
+	errHealthCheckTypeInvalid = errors.New("health check type is invalid")
+
+	// errHealthCheckValueInvalid is returned when a health check port, rise or fall is negative
+	errHealthCheckValueInvalid = errors.New("health check port, rise and fall cannot be negative")
+
+	// errAgentCheckPortRequired is returned when an agent check is configured without a port
+	errAgentCheckPortRequired = errors.New("agent check requires a port")
+
+	// errHealthCheckTypePoolOnly is returned when an origin sets a health check type other than none
+	errHealthCheckTypePoolOnly = errors.New("health check type can only be set to none on an origin, set it on the pool")
+
+	// errHealthCheckHTTPPoolOnly is returned when an origin sets http health check settings
+	errHealthCheckHTTPPoolOnly = errors.New("http health check settings can only be set on a pool")
+
+	// errBalanceAlgorithmInvalid is returned when an unsupported load balancing algorithm is configured
+	errBalanceAlgorithmInvalid = errors.New("balance algorithm is invalid")
+
+	// errPersistenceTypeInvalid is returned when an unsupported session persistence type is configured
+	errPersistenceTypeInvalid = errors.New("persistence type is invalid")
+
+	// errPortModeInvalid is returned when an unsupported port mode is configured
+	errPortModeInvalid = errors.New("port mode is invalid")
+
+	// errRoutesRequireHTTP is returned when routes are configured on a port that is not in http mode
+	errRoutesRequireHTTP = errors.New("routes require an http mode port")
+
+	// errRouteInvalid is returned when a route has no pool or no host and path conditions
+	errRouteInvalid = errors.New("route requires a pool and a host or path prefix")
+
+	// errRoutePoolNotFound is returned when a route points to a pool which is not attached to the port
+	errRoutePoolNotFound = errors.New("route pool is not attached to port")
+
+	// errPoolModeConflict is returned when a pool is attached to both tcp and http mode ports
+	errPoolModeConflict = errors.New("pool is attached to both tcp and http mode ports")
+
+	// errPersistenceRequiresHTTP is returned when cookie persistence is configured on a pool attached to a tcp mode port
+	errPersistenceRequiresHTTP = errors.New("cookie persistence requires http mode ports")
+
+	// errTLSCertificatesRequired is returned when TLS is configured on a port without certificates
+	errTLSCertificatesRequired = errors.New("tls requires at least one certificate")
+
+	// errTLSVersionInvalid is returned when an unsupported minimum TLS version is configured
+	errTLSVersionInvalid = errors.New("tls version is invalid")
+
+	// errOriginTLSCARequired is returned when TLS to origins is configured without a CA bundle to verify them
+	errOriginTLSCARequired = errors.New("origin tls requires a ca bundle")
+
+	// errCertificateNameConflict is returned when two certificate files share the same file name
+	errCertificateNameConflict = errors.New("certificate file names must be unique")
+
+	// errCertificateUploadFailure is returned when a certificate cannot be uploaded to the dataplaneapi storage
+	errCertificateUploadFailure = errors.New("failed to upload certificate")
+
+	// errFrontendAttrFailure is returned when an attribute cannot be applied to a frontend
+	errFrontendAttrFailure = errors.New("failed to create frontend attr")
+
+	// errBackendAttrFailure is returned when an attribute cannot be applied to a backend
+	errBackendAttrFailure = errors.New("failed to create backend attr")
+
+	// errResolversSectionLabelFailure is returned when a resolvers section cannot be created
+	errResolversSectionLabelFailure = errors.New("failed to create resolvers section with label")
+
+	// errResolversAttrFailure is returned when an attribute cannot be applied to a resolvers section
+	errResolversAttrFailure = errors.New("failed to create resolvers attr")
+
+	// errSubscriberStopped is returned when the subscriber stopped listening to a subscription
+	errSubscriberStopped = errors.New("subscriber stopped listening")
+
+	// errSubscriberNotListening is returned when the subscriptions are not all listened to
+	errSubscriberNotListening = errors.New("subscriptions are not active")
+
+	// errDataPlaneUnavailable is returned when the dataplaneapi cannot be reached
+	errDataPlaneUnavailable = errors.New("dataplaneapi is unavailable")
+
+	// errNotReconciled is returned until the first reconcile succeeded
+	errNotReconciled = errors.New("config not reconciled yet")
 )
 
 func newLabelError(label string, err error, labelErr error) error {
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"context"
+	"errors"
+)
+
+// Live returns an error when the manager can no longer process events, as the subscriber
+// stopped listening to one of its subscriptions
+func (m *Manager) Live() error {
+	if m.Subscriber != nil && !m.Subscriber.Alive() {
+		return errSubscriberStopped
+	}
+
+	return nil
+}
+
+// Ready returns an error when the manager cannot keep haproxy up to date: the dataplaneapi is
+// unreachable, the subscriptions are not active or no reconcile succeeded yet. Running from the
+// last known good config while the lb api is unavailable counts as reconciled.
+func (m *Manager) Ready(ctx context.Context) error {
+	var errs []error
+
+	if !m.DataPlaneClient.APIIsReady(ctx) {
+		errs = append(errs, errDataPlaneUnavailable)
+	}
+
+	if m.Subscriber != nil && !m.Subscriber.Listening() {
+		errs = append(errs, errSubscriberNotListening)
+	}
+
+	if !m.reconciled.Load() {
+		errs = append(errs, errNotReconciled)
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,34 +2,99 @@ package manager
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	parser "github.com/haproxytech/config-parser/v4"
 	"github.com/haproxytech/config-parser/v4/options"
-	"github.com/haproxytech/config-parser/v4/types"
 
 	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
 
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/history"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/lbsource"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
+
 	"go.infratographer.com/x/events"
 	"go.infratographer.com/x/gidx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+const (
+	// lbAPIRetryInitialDelay is the first delay between reconciles while the lb api is unavailable at startup
+	lbAPIRetryInitialDelay = 1 * time.Second
+
+	// lbAPIRetryMaxDelay caps the delay between reconciles while the lb api is unavailable at startup
+	lbAPIRetryMaxDelay = 1 * time.Minute
+)
+
 type lbAPI interface {
 	GetLoadBalancer(ctx context.Context, id string) (*lbapi.LoadBalancer, error)
 }
 
+// lbWatcher is implemented by load balancer sources reporting changes themselves, such as files
+type lbWatcher interface {
+	Watch(ctx context.Context, changed func()) error
+}
+
 type dataPlaneAPI interface {
-	PostConfig(ctx context.Context, config string) error
+	PostConfig(ctx context.Context, config string) (string, error)
+	WaitForReload(ctx context.Context, reloadID string) error
+	GetConfig(ctx context.Context) (string, error)
 	CheckConfig(ctx context.Context, config string) error
+	UploadCertificate(ctx context.Context, name string, contents []byte) error
 	APIIsReady(ctx context.Context) bool
 	WaitForDataPlaneReady(ctx context.Context, retries int, sleep time.Duration) error
 }
 
+// endpointReporter is implemented by dataplane clients applying configs to several endpoints
+type endpointReporter interface {
+	Endpoints() []dataplaneapi.EndpointStatus
+}
+
 type eventSubscriber interface {
 	Listen() error
 	Subscribe(topic string) error
+	Listening() bool
+	Alive() bool
+}
+
+// AddressFamily is the address family frontends bind their ports on
+//
+// TODO: read the address family from the load balancer once the lb api schema has one, it is
+// only set per manager instance until then.
+type AddressFamily string
+
+const (
+	// AddressFamilyIPv4 binds frontend ports on IPv4 only
+	AddressFamilyIPv4 AddressFamily = "ipv4"
+
+	// AddressFamilyIPv6 binds frontend ports on IPv6 only
+	AddressFamilyIPv6 AddressFamily = "ipv6"
+
+	// AddressFamilyDual binds frontend ports on both IPv4 and IPv6
+	AddressFamilyDual AddressFamily = "dual"
+)
+
+// ParseAddressFamily returns the AddressFamily for the given string, an empty string defaults to ipv4
+func ParseAddressFamily(af string) (AddressFamily, error) {
+	switch AddressFamily(af) {
+	case "", AddressFamilyIPv4:
+		return AddressFamilyIPv4, nil
+	case AddressFamilyIPv6, AddressFamilyDual:
+		return AddressFamily(af), nil
+	default:
+		return "", fmt.Errorf("%w: %q", errAddressFamilyInvalid, af)
+	}
 }
 
 // Manager contains configuration and client connections
@@ -43,9 +108,41 @@ type Manager struct {
 	LBClient                      lbAPI
 	ManagedLBID                   gidx.PrefixedID
 	BaseCfgPath                   string
+	OverridesPath                 string
+	AddressFamily                 AddressFamily
+	CertificatesDir               string
+	UpdateDebounce                time.Duration
+	UpdateMaxWait                 time.Duration
+	ResyncInterval                time.Duration
+	StateDir                      string
+	FrontendCheckHost             string
+	History                       *history.Store
+
+	// reconciler serializes config updates, started with the first one
+	reconciler     *reconciler
+	reconcilerOnce sync.Once
+
+	// drifted is set when the live config drifted from the expected one, forcing the next update
+	// to apply the config again. It is only used by the reconciler worker.
+	drifted bool
+
+	// skippedUpdates counts the updates skipped as the rendered config did not change
+	skippedUpdates uint64
 
-	// currentConfig for unit testing
-	currentConfig string
+	// driftRepairs counts the updates which found the live config drifted from the expected one
+	driftRepairs uint64
+
+	// rollbacks counts the applies which failed and were rolled back to the last applied config
+	rollbacks uint64
+
+	// reconciled is set once an update succeeded or the last known good config was applied
+	reconciled atomic.Bool
+
+	// paused stops automatic applies
+	paused atomic.Bool
+
+	// status records what the manager last did for the admin api
+	status status
 }
 
 // Run subscribes to a NATS subject and updates the haproxy config via dataplaneapi
@@ -60,10 +157,6 @@ func (m *Manager) Run() error {
 		m.Logger.Fatal("loadbalancer api client is not initialized")
 	}
 
-	if m.Subscriber == nil {
-		m.Logger.Fatal("pubsub subscriber client is not initialized")
-	}
-
 	// wait until the Data Plane API is running
 	if err := m.DataPlaneClient.WaitForDataPlaneReady(m.Context, m.DataPlaneConnectRetries, m.DataPlaneConnectRetryInterval); err != nil {
 		m.Logger.Fatal("unable to reach dataplaneapi. is it running?")
@@ -74,10 +167,24 @@ func (m *Manager) Run() error {
 		return nil
 	default:
 		// use desired config on start
-		if err := m.updateConfigToLatest(); err != nil {
+		if err := m.initialize(); err != nil {
 			m.Logger.Fatalw("failed to initialize the config", zap.Error(err))
 		}
 
+		if m.ResyncInterval > 0 {
+			go m.resync()
+		}
+
+		if w, ok := m.LBClient.(lbWatcher); ok {
+			go m.watch(w)
+		}
+
+		// without a subscriber, updates rely on the load balancer source watch and resyncs
+		if m.Subscriber == nil {
+			<-m.Context.Done()
+			return nil
+		}
+
 		// listen for event messages on subject(s)
 		if err := m.Subscriber.Listen(); err != nil {
 			return err
@@ -89,7 +196,7 @@ func (m *Manager) Run() error {
 
 // loadbalancerTargeted returns true if this ChangeMessage is targeted to the
 // loadbalancerID the manager is configured to act on
-func (m Manager) loadbalancerTargeted(msg events.ChangeMessage) bool {
+func (m *Manager) loadbalancerTargeted(msg events.ChangeMessage) bool {
 	m.Logger.Debugw("change msg received",
 		"event-type", msg.EventType,
 		"subjectID", msg.SubjectID,
@@ -112,6 +219,21 @@ func (m Manager) loadbalancerTargeted(msg events.ChangeMessage) bool {
 func (m *Manager) ProcessMsg(msg events.Message[events.ChangeMessage]) error {
 	changeMsg := msg.Message()
 
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// continue the trace of the message
+	_, span := tracer.Start(changeMsg.GetTraceContext(ctx), "manager.ProcessMsg", trace.WithAttributes(
+		attribute.String("event.message.id", msg.ID()),
+		attribute.String("event.message.topic", msg.Topic()),
+		attribute.String("event.type", changeMsg.EventType),
+		attribute.String("event.subject_id", changeMsg.SubjectID.String()),
+		attribute.String("loadbalancer.id", m.ManagedLBID.String()),
+	))
+	defer span.End()
+
 	mlogger := m.Logger.With(
 		"event.message.id", msg.ID(),
 		"event.message.topic", msg.Topic(),
@@ -129,16 +251,36 @@ func (m *Manager) ProcessMsg(msg events.Message[events.ChangeMessage]) error {
 	case events.UpdateChangeType:
 		// drop msg, if not targeted for this lb
 		if !m.loadbalancerTargeted(changeMsg) {
+			metrics.EventsTotal.WithLabelValues(metrics.EventFiltered).Inc()
+			span.SetAttributes(attribute.Bool("event.filtered", true))
+
 			return nil
 		}
 
 		mlogger.Infow("msg received")
 
-		if err := m.updateConfigToLatest(); err != nil {
+		m.status.received(EventStatus{
+			ID:         msg.ID(),
+			Type:       changeMsg.EventType,
+			SubjectID:  changeMsg.SubjectID.String(),
+			ReceivedAt: time.Now().UTC(),
+		})
+
+		req := reconcileRequest{
+			eventIDs: []string{msg.ID()},
+			spans:    []trace.SpanContext{span.SpanContext()},
+		}
+
+		if err := m.reconcile(req); err != nil {
 			mlogger.Errorw("failed to update haproxy config")
+			recordError(span, err)
+
 			return err
 		}
 	default:
+		metrics.EventsTotal.WithLabelValues(metrics.EventFiltered).Inc()
+		span.SetAttributes(attribute.Bool("event.filtered", true))
+
 		m.Logger.Debugw("ignoring msg, not a create/update/delete event",
 			zap.String("event-type", changeMsg.EventType),
 			zap.String("messageID", msg.ID()))
@@ -147,92 +289,415 @@ func (m *Manager) ProcessMsg(msg events.Message[events.ChangeMessage]) error {
 	return nil
 }
 
-// updateConfigToLatest update the haproxy cfg to either baseline or one requested from lbapi with optional lbID param
-func (m *Manager) updateConfigToLatest() error {
-	m.Logger.Infow("updating haproxy config", zap.String("loadbalancerID", m.ManagedLBID.String()))
+// initialize applies the desired config on start. When the lb api is unavailable, the last known good
+// config is applied instead and the desired config once the lb api is back. A load balancer the lb api
+// does not know is not served from the last known good config. The manager is ready once the last
+// known good config is applied, as haproxy serves a config which worked.
+func (m *Manager) initialize() error {
+	err := m.reconcile(reconcileRequest{})
+	if err == nil || !errors.Is(err, errLoadBalancerUnavailable) || m.StateDir == "" {
+		return err
+	}
 
-	if m.ManagedLBID == "" {
-		return errLoadBalancerIDParamInvalid
+	m.Logger.Warnw("loadbalancer api is unavailable, applying the last known good config",
+		zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+
+	if lkgErr := m.applyLastKnownGood(); lkgErr != nil {
+		return errors.Join(err, lkgErr)
+	}
+
+	m.reconciled.Store(true)
+
+	go m.reconcileUntilAvailable()
+
+	return nil
+}
+
+// applyLastKnownGood applies the config persisted in the state dir
+func (m *Manager) applyLastKnownGood() error {
+	config, meta, err := loadState(m.StateDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errLastKnownGoodUnavailable, err)
+	}
+
+	if meta.LoadBalancerID != m.ManagedLBID.String() {
+		return newLabelError(meta.LoadBalancerID, errLastKnownGoodUnavailable, errLastKnownGoodMismatch)
+	}
+
+	if err := m.DataPlaneClient.CheckConfig(m.Context, config); err != nil {
+		return err
+	}
+
+	reloadID, err := m.DataPlaneClient.PostConfig(m.Context, config)
+	if err != nil {
+		return err
+	}
+
+	if err := m.DataPlaneClient.WaitForReload(m.Context, reloadID); err != nil {
+		return err
+	}
+
+	m.Logger.Infow("last known good config applied",
+		zap.String("loadbalancerID", m.ManagedLBID.String()),
+		zap.Time("appliedAt", meta.AppliedAt))
+
+	m.status.applied(config, meta.Hash)
+
+	metrics.ObserveApplySuccess(time.Now())
+
+	return nil
+}
+
+// reconcileUntilAvailable retries reconciling, with a growing delay, until the lb api is available again
+func (m *Manager) reconcileUntilAvailable() {
+	delay := lbAPIRetryInitialDelay
+
+	for {
+		select {
+		case <-m.Context.Done():
+			return
+		case <-time.After(delay):
+			err := m.reconcile(reconcileRequest{})
+			if err == nil {
+				m.Logger.Infow("loadbalancer api is available again, config reconciled", zap.String("loadbalancerID", m.ManagedLBID.String()))
+				return
+			}
+
+			m.Logger.Warnw("failed to reconcile after applying the last known good config",
+				zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+
+			delay = min(delay*2, lbAPIRetryMaxDelay)
+		}
+	}
+}
+
+// resync periodically reconciles with the lb api and repairs drift of the live config, covering lost events
+// and changes made to haproxy by hand
+func (m *Manager) resync() {
+	ticker := time.NewTicker(m.ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.Context.Done():
+			return
+		case <-ticker.C:
+			m.Logger.Debugw("resyncing haproxy config", zap.String("loadbalancerID", m.ManagedLBID.String()))
+
+			if err := m.reconcile(reconcileRequest{verify: true}); err != nil {
+				m.Logger.Errorw("failed to resync haproxy config", zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+			}
+		}
+	}
+}
+
+// watch updates the haproxy config whenever the load balancer source reports a change
+func (m *Manager) watch(w lbWatcher) {
+	err := w.Watch(m.Context, func() {
+		m.Logger.Debugw("load balancer source changed", zap.String("loadbalancerID", m.ManagedLBID.String()))
+
+		// the reconciler collapses changes reported while an update runs
+		go func() {
+			if err := m.reconcile(reconcileRequest{}); err != nil {
+				m.Logger.Errorw("failed to update haproxy config", zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+			}
+		}()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		m.Logger.Errorw("stopped watching the load balancer source", zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+	}
+}
+
+// update runs a reconcile, checking the live config for drift when requested. Automatic
+// updates are skipped while applies are paused.
+func (m *Manager) update(req reconcileRequest) (err error) {
+	if m.paused.Load() && !req.manual {
+		metrics.UpdatesSkippedTotal.WithLabelValues(metrics.UpdateSkippedPaused).Inc()
+		m.Logger.Infow("automatic config applies paused, skipping update", zap.String("loadbalancerID", m.ManagedLBID.String()))
+
+		return nil
+	}
+
+	ctx, span := m.startReconcileSpan(req)
+	defer func() {
+		if err != nil {
+			m.status.failed(err)
+		}
+
+		endSpan(span, err)
+	}()
+
+	if err := m.updateConfigToLatest(ctx, req.verify, req.eventIDs...); err != nil {
+		return err
 	}
 
+	m.reconciled.Store(true)
+
+	return nil
+}
+
+// checkDrift compares the live config with the expected one rendered from the load balancer,
+// forcing the next update to apply the config again when they differ
+func (m *Manager) checkDrift(ctx context.Context, expected string) error {
+	live, err := m.DataPlaneClient.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if dataplaneapi.NormalizeConfig(live) == dataplaneapi.NormalizeConfig(expected) {
+		return nil
+	}
+
+	drifts := atomic.AddUint64(&m.driftRepairs, 1)
+	metrics.DriftRepairsTotal.Inc()
+
+	m.Logger.Warnw("live haproxy config drifted from the expected config, repairing",
+		zap.String("loadbalancerID", m.ManagedLBID.String()),
+		zap.Uint64("driftRepairs", drifts))
+
+	m.drifted = true
+
+	return nil
+}
+
+// DriftRepairs returns the number of times the live config was found drifted and applied again
+func (m *Manager) DriftRepairs() uint64 {
+	return atomic.LoadUint64(&m.driftRepairs)
+}
+
+// Render returns the haproxy config of a load balancer, the base config merged with the load balancer and the local overrides
+func (m *Manager) Render(lb *lbapi.LoadBalancer) (string, error) {
+	cfg, _, err := m.render(lb)
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.String(), nil
+}
+
+// render merges a load balancer and the local overrides into the base config
+func (m *Manager) render(lb *lbapi.LoadBalancer) (parser.Parser, *Overrides, error) {
+	defer func(start time.Time) {
+		metrics.RenderDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
 	// load base config
 	cfg, err := parser.New(options.Path(m.BaseCfgPath), options.NoNamedDefaultsFrom)
 	if err != nil {
-		m.Logger.Fatalw("failed to load haproxy base config", zap.Error(err))
+		return nil, nil, fmt.Errorf("%w: %v", errBaseConfigInvalid, err)
+	}
+
+	// load local port, pool and origin overrides
+	var overrides *Overrides
+
+	if m.OverridesPath != "" {
+		overrides, err = LoadOverrides(m.OverridesPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// merge response
+	cfg, err = mergeConfig(cfg, lb,
+		withAddressFamily(m.AddressFamily),
+		withOverrides(overrides),
+		withCertificatesDir(m.CertificatesDir),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, overrides, nil
+}
+
+// updateConfigToLatest update the haproxy cfg to either baseline or one requested from lbapi with optional lbID param.
+// With verify, an unchanged config is only skipped when the live config matches it.
+func (m *Manager) updateConfigToLatest(ctx context.Context, verify bool, eventIDs ...string) error {
+	m.Logger.Infow("updating haproxy config", zap.String("loadbalancerID", m.ManagedLBID.String()))
+
+	if m.ManagedLBID == "" {
+		return errLoadBalancerIDParamInvalid
 	}
 
 	// get desired state from lbapi
-	lb, err := m.LBClient.GetLoadBalancer(m.Context, m.ManagedLBID.String())
+	lbCtx, span := tracer.Start(ctx, "lbapi.GetLoadBalancer")
+	start := time.Now()
+	lb, err := m.LBClient.GetLoadBalancer(lbCtx, m.ManagedLBID.String())
+
+	metrics.LBAPIRequestDuration.Observe(time.Since(start).Seconds())
+	endSpan(span, err)
+
 	if err != nil {
+		metrics.LBAPIErrorsTotal.Inc()
+
+		if errors.Is(err, lbapi.ErrLBNotfound) || errors.Is(err, lbsource.ErrLoadBalancerNotFound) {
+			return fmt.Errorf("%w: %v", errLoadBalancerNotFound, err)
+		}
+
+		return fmt.Errorf("%w: %v", errLoadBalancerUnavailable, err)
+	}
+
+	_, span = tracer.Start(ctx, "manager.mergeConfig")
+	cfg, overrides, err := m.render(lb)
+
+	endSpan(span, err)
+
+	if err != nil {
+		if errors.Is(err, errBaseConfigInvalid) {
+			m.Logger.Fatalw("failed to load haproxy base config", zap.Error(err))
+		}
+
 		return err
 	}
 
-	// merge response
-	cfg, err = mergeConfig(cfg, lb)
+	certs, err := readCertificates(overrides)
 	if err != nil {
 		return err
 	}
 
+	// skip the dataplaneapi round trip and haproxy reload when nothing changed
+	config := cfg.String()
+	hash := configHash(config, certs)
+
+	unchanged := false
+	if _, applied := m.AppliedConfig(); hash == applied && !m.drifted {
+		unchanged = true
+
+		// an unchanged config may still have drifted from the live one
+		if verify {
+			if err := m.checkDrift(ctx, config); err != nil {
+				return err
+			}
+
+			unchanged = !m.drifted
+		}
+	}
+
+	if unchanged {
+		skipped := atomic.AddUint64(&m.skippedUpdates, 1)
+		metrics.UpdatesSkippedTotal.WithLabelValues(metrics.UpdateSkippedUnchanged).Inc()
+
+		m.Logger.Infow("config unchanged, skipping update",
+			zap.String("loadbalancerID", m.ManagedLBID.String()),
+			zap.Uint64("skippedUpdates", skipped))
+
+		return nil
+	}
+
+	// certificates must be in place before the config referencing them is validated
+	if err := m.uploadCertificates(ctx, certs); err != nil {
+		return err
+	}
+
 	// check dataplaneapi to see if a valid config
-	if err := m.DataPlaneClient.CheckConfig(m.Context, cfg.String()); err != nil {
+	checkCtx, span := tracer.Start(ctx, "dataplaneapi.CheckConfig")
+	err = m.DataPlaneClient.CheckConfig(checkCtx, config)
+
+	endSpan(span, err)
+
+	if err != nil {
 		return err
 	}
 
 	// post dataplaneapi
-	if err := m.DataPlaneClient.PostConfig(m.Context, cfg.String()); err != nil {
+	postCtx, span := tracer.Start(ctx, "dataplaneapi.PostConfig")
+	reloadID, err := m.DataPlaneClient.PostConfig(postCtx, config)
+
+	span.SetAttributes(attribute.String("reload.id", reloadID))
+	endSpan(span, err)
+
+	if err != nil {
 		return err
 	}
 
+	// make sure haproxy runs the new config, or go back to the last one that worked
+	if err := m.confirmApply(reloadID, lb); err != nil {
+		return m.rollback(err)
+	}
+
 	m.Logger.Infow("config successfully updated", zap.String("loadbalancerID", m.ManagedLBID.String()))
-	m.currentConfig = cfg.String() // for testing
+	m.drifted = false
+	m.status.applied(config, hash)
 
-	return nil
-}
+	metrics.ObserveApplySuccess(time.Now())
 
-// mergeConfig takes the response from lb api, merges with the base haproxy config and returns it
-func mergeConfig(cfg parser.Parser, lb *lbapi.LoadBalancer) (parser.Parser, error) {
-	for _, p := range lb.Ports.Edges {
-		// create port
-		if err := cfg.SectionsCreate(parser.Frontends, p.Node.ID); err != nil {
-			return nil, newLabelError(p.Node.ID, errFrontendSectionLabelFailure, err)
+	if m.StateDir != "" {
+		meta := stateMetadata{
+			LoadBalancerID: m.ManagedLBID.String(),
+			Hash:           hash,
+			AppliedAt:      time.Now().UTC(),
 		}
 
-		if err := cfg.Insert(parser.Frontends, p.Node.ID, "bind", types.Bind{
-			// TODO AddressFamily?
-			Path: fmt.Sprintf("%s@:%d", "ipv4", p.Node.Number)}); err != nil {
-			return nil, newAttrError(errFrontendBindFailure, err)
+		// the config is applied, a failure to persist it only affects offline startups
+		if err := saveState(m.StateDir, config, meta); err != nil {
+			m.Logger.Warnw("failed to persist the last known good config", zap.String("stateDir", m.StateDir), zap.Error(err))
 		}
+	}
 
-		// map frontend to backend
-		if err := cfg.Set(parser.Frontends, p.Node.ID, "use_backend", types.UseBackend{Name: p.Node.ID}); err != nil {
-			return nil, newAttrError(errUseBackendFailure, err)
+	if m.History != nil {
+		entry := history.Entry{
+			LoadBalancerID: m.ManagedLBID.String(),
+			Hash:           hash,
+			EventIDs:       eventIDs,
 		}
 
-		// create backend
-		if err := cfg.SectionsCreate(parser.Backends, p.Node.ID); err != nil {
-			return nil, newLabelError(p.Node.ID, errBackendSectionLabelFailure, err)
+		if _, err := m.History.Add(entry, config); err != nil {
+			m.Logger.Warnw("failed to record the applied config in the history", zap.Error(err))
 		}
+	}
 
-		for _, pool := range p.Node.Pools {
-			for _, origin := range pool.Origins.Edges {
-				srvAddr := fmt.Sprintf("%s:%d check port %d", origin.Node.Target, origin.Node.PortNumber, origin.Node.PortNumber)
-				srvAddr += fmt.Sprintf(" weight %d", origin.Node.Weight)
+	return nil
+}
+
+// SkippedUpdates returns the number of updates skipped as the rendered config did not change
+func (m *Manager) SkippedUpdates() uint64 {
+	return atomic.LoadUint64(&m.skippedUpdates)
+}
 
-				if !origin.Node.Active {
-					srvAddr += " disabled"
-				}
+// certificate is a certificate file uploaded to the dataplaneapi storage
+type certificate struct {
+	name     string
+	contents []byte
+}
 
-				srvr := types.Server{
-					Name:    fmt.Sprintf("%s::%s", origin.Node.ID, origin.Node.Target),
-					Address: srvAddr,
-				}
+// readCertificates reads the port certificates and the pool CA bundles and client certificates
+func readCertificates(overrides *Overrides) ([]certificate, error) {
+	certs := []certificate{}
 
-				if err := cfg.Set(parser.Backends, p.Node.ID, "server", srvr); err != nil {
-					return nil, newLabelError(p.Node.ID, errBackendServerFailure, err)
-				}
-			}
+	for _, path := range overrides.certificates() {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, newLabelError(path, errCertificateUploadFailure, err)
 		}
+
+		certs = append(certs, certificate{name: filepath.Base(path), contents: contents})
 	}
 
-	return cfg, nil
+	return certs, nil
+}
+
+// configHash returns a hash of the config and the certificates it references
+func configHash(config string, certs []certificate) string {
+	h := sha256.New()
+	h.Write([]byte(config))
+
+	for _, cert := range certs {
+		h.Write([]byte(cert.name))
+		h.Write(cert.contents)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// uploadCertificates uploads certificates to the dataplaneapi storage
+func (m *Manager) uploadCertificates(ctx context.Context, certs []certificate) error {
+	for _, cert := range certs {
+		if err := m.DataPlaneClient.UploadCertificate(ctx, cert.name, cert.contents); err != nil {
+			return newLabelError(cert.name, errCertificateUploadFailure, err)
+		}
+
+		m.Logger.Debugw("certificate uploaded", zap.String("certificate", cert.name))
+	}
+
+	return nil
 }
@@ -4,15 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	parser "github.com/haproxytech/config-parser/v4"
 	"github.com/haproxytech/config-parser/v4/options"
+	"github.com/haproxytech/config-parser/v4/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 
 	"go.infratographer.com/x/events"
@@ -21,7 +31,11 @@ import (
 
 	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
 
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/history"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/lbsource"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/manager/mock"
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
 	"go.infratographer.com/loadbalancer-manager-haproxy/internal/pubsub"
 )
 
@@ -31,14 +45,42 @@ const (
 )
 
 func TestMergeConfig(t *testing.T) {
+	healthCheckOverrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-healthcheck.yaml"))
+	require.NoError(t, err)
+
+	balanceOverrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-balance.yaml"))
+	require.NoError(t, err)
+
+	httpOverrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-http.yaml"))
+	require.NoError(t, err)
+
+	tlsOverrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-tls.yaml"))
+	require.NoError(t, err)
+
+	originTLSOverrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-origin-tls.yaml"))
+	require.NoError(t, err)
+
 	MergeConfigTests := []struct {
 		name                string
 		testInput           lbapi.LoadBalancer
+		opts                []mergeOption
 		expectedCfgFilename string
 	}{
-		{"ssh service one pool", mergeTestData1, "lb-ex-1-exp.cfg"},
-		{"ssh service two pools", mergeTestData2, "lb-ex-2-exp.cfg"},
-		{"http and https", mergeTestData3, "lb-ex-3-exp.cfg"},
+		{"ssh service one pool", mergeTestData1, nil, "lb-ex-1-exp.cfg"},
+		{"ssh service two pools", mergeTestData2, nil, "lb-ex-2-exp.cfg"},
+		{"http and https", mergeTestData3, nil, "lb-ex-3-exp.cfg"},
+		{"ssh service one pool ipv6", mergeTestData1, []mergeOption{withAddressFamily(AddressFamilyIPv6)}, "lb-ex-1-ipv6-exp.cfg"},
+		{"ssh service one pool dual stack", mergeTestData1, []mergeOption{withAddressFamily(AddressFamilyDual)}, "lb-ex-1-dual-exp.cfg"},
+		{"http and https dual stack", mergeTestData3, []mergeOption{withAddressFamily(AddressFamilyDual)}, "lb-ex-3-dual-exp.cfg"},
+		{"ipv6 and fqdn origins", mergeTestData4, nil, "lb-ex-4-exp.cfg"},
+		{"shared pool and origins", mergeTestData5, nil, "lb-ex-5-exp.cfg"},
+		{"health check overrides", mergeTestData2, []mergeOption{withOverrides(healthCheckOverrides)}, "lb-ex-2-healthcheck-exp.cfg"},
+		{"balance and persistence overrides", mergeTestData2, []mergeOption{withOverrides(balanceOverrides)}, "lb-ex-2-balance-exp.cfg"},
+		{"balance and persistence overrides dual stack", mergeTestData2, []mergeOption{withOverrides(balanceOverrides), withAddressFamily(AddressFamilyDual)}, "lb-ex-2-balance-dual-exp.cfg"},
+		{"http routing overrides", mergeTestData5, []mergeOption{withOverrides(httpOverrides)}, "lb-ex-5-http-exp.cfg"},
+		{"tls termination overrides", mergeTestData3, []mergeOption{withOverrides(tlsOverrides)}, "lb-ex-3-tls-exp.cfg"},
+		{"tls termination overrides dual stack", mergeTestData3, []mergeOption{withOverrides(tlsOverrides), withAddressFamily(AddressFamilyDual), withCertificatesDir("/usr/local/etc/haproxy/ssl")}, "lb-ex-3-tls-dual-exp.cfg"},
+		{"origin tls overrides", mergeTestData2, []mergeOption{withOverrides(originTLSOverrides)}, "lb-ex-2-origin-tls-exp.cfg"},
 	}
 
 	for _, tt := range MergeConfigTests {
@@ -51,7 +93,7 @@ func TestMergeConfig(t *testing.T) {
 			cfg, err := parser.New(options.Path("../../.devcontainer/config/haproxy.cfg"), options.NoNamedDefaultsFrom)
 			require.Nil(t, err)
 
-			newCfg, err := mergeConfig(cfg, &tt.testInput)
+			newCfg, err := mergeConfig(cfg, &tt.testInput, tt.opts...)
 			assert.Nil(t, err)
 
 			t.Log("Generated config ===> ", newCfg.String())
@@ -64,6 +106,422 @@ func TestMergeConfig(t *testing.T) {
 	}
 }
 
+func TestMergeConfigInvalidTarget(t *testing.T) {
+	cfg, err := parser.New(options.Path(testBaseCfgPath), options.NoNamedDefaultsFrom)
+	require.Nil(t, err)
+
+	lb := lbapi.LoadBalancer{
+		ID: "loadbal-test",
+		Ports: lbapi.Ports{
+			Edges: []lbapi.PortEdges{
+				{
+					Node: lbapi.PortNode{
+						ID:     "loadprt-test",
+						Number: 22,
+						Pools: []lbapi.Pool{
+							{
+								ID: "loadpol-test",
+								Origins: lbapi.Origins{
+									Edges: []lbapi.OriginEdges{
+										{
+											Node: lbapi.OriginNode{
+												ID:         "loadogn-test1",
+												Target:     "not a valid target",
+												PortNumber: 22,
+												Active:     true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err = mergeConfig(cfg, &lb)
+	require.ErrorIs(t, err, errOriginTargetInvalid)
+}
+
+func TestMergeConfigInvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name        string
+		overrides   string
+		expectedErr error
+	}{
+		{"route to pool not on port", "ports:\n  loadprt-testa:\n    mode: http\n  loadprt-testb:\n    mode: http\n    routes:\n      - host: app.example.com\n        pool: loadpol-other\n", errRoutePoolNotFound},
+		{"pool on tcp and http ports", "ports:\n  loadprt-testa:\n    mode: http\n", errPoolModeConflict},
+		{"cookie persistence on tcp port", "pools:\n  loadpol-other:\n    persistence:\n      type: cookie\n", errPersistenceRequiresHTTP},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+			require.NoError(t, os.WriteFile(path, []byte(tt.overrides), 0o600))
+
+			overrides, err := LoadOverrides(path)
+			require.NoError(t, err)
+
+			cfg, err := parser.New(options.Path(testBaseCfgPath), options.NoNamedDefaultsFrom)
+			require.Nil(t, err)
+
+			_, err = mergeConfig(cfg, &mergeTestData5, withOverrides(overrides))
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	t.Run("loads health check overrides", func(t *testing.T) {
+		overrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-healthcheck.yaml"))
+		require.NoError(t, err)
+
+		require.NotNil(t, overrides.pool("loadpol-test").HealthCheck)
+		assert.Equal(t, HealthCheckHTTP, overrides.pool("loadpol-test").HealthCheck.Type)
+		assert.Equal(t, "/healthz", overrides.pool("loadpol-test").HealthCheck.HTTP.Path)
+		assert.Equal(t, HealthCheckNone, overrides.origin("loadogn-test3").HealthCheck.Type)
+		assert.Nil(t, overrides.pool("loadpol-unknown").HealthCheck)
+	})
+
+	t.Run("nil overrides are empty", func(t *testing.T) {
+		var overrides *Overrides
+
+		assert.Nil(t, overrides.pool("loadpol-test").HealthCheck)
+		assert.Nil(t, overrides.origin("loadogn-test").HealthCheck)
+	})
+
+	t.Run("errors on invalid health check type", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("pools:\n  loadpol-test:\n    healthCheck:\n      type: udp\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "health check type is invalid")
+	})
+
+	t.Run("errors on agent check without port", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("pools:\n  loadpol-test:\n    healthCheck:\n      agent:\n        interval: 5s\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "agent check requires a port")
+	})
+
+	t.Run("errors on origin health check type", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("origins:\n  loadogn-test:\n    healthCheck:\n      type: http\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "health check type can only be set to none on an origin")
+	})
+
+	t.Run("errors on origin http health check settings", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("origins:\n  loadogn-test:\n    healthCheck:\n      http:\n        path: /healthz\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "http health check settings can only be set on a pool")
+	})
+
+	t.Run("errors on invalid balance algorithm", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("pools:\n  loadpol-test:\n    balance:\n      algorithm: fastest\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "balance algorithm is invalid")
+	})
+
+	t.Run("errors on invalid persistence type", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("pools:\n  loadpol-test:\n    persistence:\n      type: sticky\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "persistence type is invalid")
+	})
+
+	t.Run("errors on routes on a tcp port", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("ports:\n  loadprt-test:\n    routes:\n      - host: app.example.com\n        pool: loadpol-test\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "routes require an http mode port")
+	})
+
+	t.Run("errors on route without a match", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("ports:\n  loadprt-test:\n    mode: http\n    routes:\n      - pool: loadpol-test\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "route requires a pool")
+	})
+
+	t.Run("errors on invalid tls version", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("ports:\n  loadprt-test:\n    tls:\n      certificates: [a.pem]\n      minVersion: SSLv3\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "tls version is invalid")
+	})
+
+	t.Run("errors on origin tls without ca", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("pools:\n  loadpol-test:\n    tls:\n      sni: example.com\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "origin tls requires a ca bundle")
+	})
+
+	t.Run("lists port certificates and pool ca bundles", func(t *testing.T) {
+		overrides, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-origin-tls.yaml"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"testdata/certs/lb-client.pem", "testdata/certs/origins-ca.pem"}, overrides.certificates())
+	})
+
+	t.Run("errors on certificates sharing a file name", func(t *testing.T) {
+		path := fmt.Sprintf("%s/overrides.yaml", t.TempDir())
+		require.NoError(t, os.WriteFile(path, []byte("ports:\n  loadprt-a:\n    tls:\n      certificates: [a/cert.pem]\n  loadprt-b:\n    tls:\n      certificates: [b/cert.pem]\n"), 0o600))
+
+		_, err := LoadOverrides(path)
+		require.ErrorIs(t, err, errOverridesInvalid)
+		assert.ErrorContains(t, err, "certificate file names must be unique")
+	})
+
+	t.Run("errors on missing file", func(t *testing.T) {
+		_, err := LoadOverrides(fmt.Sprintf("%s/%s", testDataBaseDir, "does-not-exist.yaml"))
+		require.Error(t, err)
+	})
+}
+
+func TestPoolSelection(t *testing.T) {
+	origin := func(weight int64, active bool) lbapi.OriginEdges {
+		return lbapi.OriginEdges{Node: lbapi.OriginNode{Weight: weight, Active: active}}
+	}
+
+	tests := []struct {
+		name     string
+		pools    []lbapi.Pool
+		expected []types.UseBackend
+	}{
+		{
+			name:     "no pools",
+			pools:    nil,
+			expected: nil,
+		},
+		{
+			name:     "single pool",
+			pools:    []lbapi.Pool{{ID: "loadpol-a"}},
+			expected: []types.UseBackend{{Name: "loadpol-a"}},
+		},
+		{
+			name: "weighted by active origins and ordered by id",
+			pools: []lbapi.Pool{
+				{ID: "loadpol-c", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(25, true)}}},
+				{ID: "loadpol-a", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(50, true), origin(100, false)}}},
+				{ID: "loadpol-b", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(25, true)}}},
+			},
+			expected: []types.UseBackend{
+				{Name: "loadpol-a", Cond: "if", CondTest: "{ src,crc32(1),mod(100) lt 50 }"},
+				{Name: "loadpol-b", Cond: "if", CondTest: "{ src,crc32(1),mod(100) lt 75 }"},
+				{Name: "loadpol-c"},
+			},
+		},
+		{
+			name: "pools without active origins are skipped",
+			pools: []lbapi.Pool{
+				{ID: "loadpol-a", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(50, false)}}},
+				{ID: "loadpol-b", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(50, true)}}},
+			},
+			expected: []types.UseBackend{{Name: "loadpol-b"}},
+		},
+		{
+			name: "all pools inactive falls back to the first pool",
+			pools: []lbapi.Pool{
+				{ID: "loadpol-b", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(50, false)}}},
+				{ID: "loadpol-a", Origins: lbapi.Origins{Edges: []lbapi.OriginEdges{origin(50, false)}}},
+			},
+			expected: []types.UseBackend{{Name: "loadpol-a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, poolSelection(tt.pools))
+		})
+	}
+}
+
+func TestClassifyTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected targetType
+	}{
+		{"1.2.3.4", targetIPv4},
+		{"2001:db8::1", targetIPv6},
+		{"::ffff:1.2.3.4", targetIPv4},
+		{"origin.example.com", targetFQDN},
+		{"origin.example.com.", targetFQDN},
+		{"localhost", targetFQDN},
+		{"1.2.3", targetInvalid},
+		{"-bad.example.com", targetInvalid},
+		{"bad..example.com", targetInvalid},
+		{"bad_name.example.com", targetInvalid},
+		{"", targetInvalid},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.target, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, classifyTarget(tt.target))
+		})
+	}
+}
+
+func TestParseAddressFamily(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected AddressFamily
+		errMsg   string
+	}{
+		{"defaults to ipv4", "", AddressFamilyIPv4, ""},
+		{"ipv4", "ipv4", AddressFamilyIPv4, ""},
+		{"ipv6", "ipv6", AddressFamilyIPv6, ""},
+		{"dual", "dual", AddressFamilyDual, ""},
+		{"invalid", "ipx", "", "address family is invalid"},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			af, err := ParseAddressFamily(tt.input)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, af)
+		})
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	live := `# _version=3
+global
+  maxconn 100
+
+frontend loadprt-test
+  bind ipv4@:22
+  use_backend loadpol-test
+
+backend loadpol-test
+  server loadogn-test1 1.2.3.4:2222 check port 2222 weight 10
+
+backend loadpol-old
+  server loadogn-old 1.2.3.5:22 check port 22
+`
+
+	tests := []struct {
+		name     string
+		planned  string
+		expected string
+	}{
+		{
+			name: "equivalent configs",
+			planned: `global
+    maxconn 100
+frontend loadprt-test
+    # routing
+    bind ipv4@:22
+    use_backend loadpol-test
+backend loadpol-test
+    server loadogn-test1 1.2.3.4:2222 check port 2222 weight 10
+backend loadpol-old
+    server loadogn-old 1.2.3.5:22 check port 22
+`,
+		},
+		{
+			name: "changed, added and removed sections",
+			planned: `global
+  maxconn 100
+
+frontend loadprt-test
+  bind ipv4@:22
+  use_backend loadpol-test
+
+backend loadpol-test
+  server loadogn-test1 1.2.3.4:2222 check port 2222 weight 20
+
+backend loadpol-new
+  server loadogn-new 1.2.3.6:22 check port 22
+`,
+			expected: `--- live backend loadpol-test
++++ planned backend loadpol-test
+@@ -1,2 +1,2 @@
+ backend loadpol-test
+-server loadogn-test1 1.2.3.4:2222 check port 2222 weight 10
++server loadogn-test1 1.2.3.4:2222 check port 2222 weight 20
+--- live backend loadpol-new
++++ planned backend loadpol-new
+@@ -0,0 +1,2 @@
++backend loadpol-new
++server loadogn-new 1.2.3.6:22 check port 22
+--- live backend loadpol-old
++++ planned backend loadpol-old
+@@ -1,2 +0,0 @@
+-backend loadpol-old
+-server loadogn-old 1.2.3.5:22 check port 22
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff, err := DiffConfigs(live, tt.planned)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, diff)
+		})
+	}
+}
+
 func TestUpdateConfigToLatest(t *testing.T) {
 	l, err := zap.NewDevelopmentConfig().Build()
 	logger := l.Sugar()
@@ -86,7 +544,7 @@ func TestUpdateConfigToLatest(t *testing.T) {
 			ManagedLBID: gidx.PrefixedID("loadbal-testing"),
 		}
 
-		err := mgr.updateConfigToLatest()
+		err := mgr.updateConfigToLatest(context.Background(), false)
 		assert.NotNil(t, err)
 	})
 
@@ -94,8 +552,8 @@ func TestUpdateConfigToLatest(t *testing.T) {
 		t.Parallel()
 
 		mockDataplaneAPI := &mock.DataplaneAPIClient{
-			DoPostConfig: func(ctx context.Context, config string) error {
-				return nil
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
 			},
 			DoCheckConfig: func(ctx context.Context, config string) error {
 				return errors.New("bad config") // nolint:goerr113
@@ -109,7 +567,7 @@ func TestUpdateConfigToLatest(t *testing.T) {
 		}
 
 		// initial config
-		err := mgr.updateConfigToLatest()
+		err := mgr.updateConfigToLatest(context.Background(), false)
 		require.Error(t, err)
 	})
 
@@ -119,7 +577,7 @@ func TestUpdateConfigToLatest(t *testing.T) {
 			BaseCfgPath: testBaseCfgPath,
 		}
 
-		err := mgr.updateConfigToLatest()
+		err := mgr.updateConfigToLatest(context.Background(), false)
 		require.ErrorIs(t, err, errLoadBalancerIDParamInvalid)
 	})
 
@@ -136,8 +594,8 @@ func TestUpdateConfigToLatest(t *testing.T) {
 		}
 
 		mockDataplaneAPI := &mock.DataplaneAPIClient{
-			DoPostConfig: func(ctx context.Context, config string) error {
-				return nil
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
 			},
 			DoCheckConfig: func(ctx context.Context, config string) error {
 				return nil
@@ -152,7 +610,7 @@ func TestUpdateConfigToLatest(t *testing.T) {
 			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
 		}
 
-		err := mgr.updateConfigToLatest()
+		err := mgr.updateConfigToLatest(context.Background(), false)
 		require.Nil(t, err)
 
 		contents, err := os.ReadFile(testBaseCfgPath)
@@ -160,9 +618,10 @@ func TestUpdateConfigToLatest(t *testing.T) {
 
 		// remove that 'unnamed_defaults_1' thing the haproxy parser library puts in the default section,
 		// even though the library is configured to not include default section labels
-		mgr.currentConfig = strings.ReplaceAll(mgr.currentConfig, " unnamed_defaults_1", "")
+		applied, _ := mgr.AppliedConfig()
+		applied = strings.ReplaceAll(applied, " unnamed_defaults_1", "")
 
-		assert.Equal(t, strings.TrimSpace(string(contents)), strings.TrimSpace(mgr.currentConfig))
+		assert.Equal(t, strings.TrimSpace(string(contents)), strings.TrimSpace(applied))
 	})
 
 	t.Run("successfully queries lb api and merges changes with base config", func(t *testing.T) {
@@ -228,33 +687,1184 @@ func TestUpdateConfigToLatest(t *testing.T) {
 			},
 		}
 
-		mockDataplaneAPI := &mock.DataplaneAPIClient{
-			DoPostConfig: func(ctx context.Context, config string) error {
-				return nil
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
+			},
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		err := mgr.updateConfigToLatest(context.Background(), false)
+		require.Nil(t, err)
+
+		expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-1-exp.cfg"))
+		require.Nil(t, err)
+
+		applied, _ := mgr.AppliedConfig()
+		assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(applied))
+	})
+
+	t.Run("uploads certificates before checking the config", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData3, nil
+			},
+		}
+
+		calls := []string{}
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoUploadCertificate: func(ctx context.Context, name string, contents []byte) error {
+				calls = append(calls, "upload "+name)
+				return nil
+			},
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				calls = append(calls, "check")
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				calls = append(calls, "post")
+				return "", nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			OverridesPath:   fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-tls.yaml"),
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		err := mgr.updateConfigToLatest(context.Background(), false)
+		require.Nil(t, err)
+
+		assert.Equal(t, []string{"upload example.com.pem", "upload example.org.pem", "check", "post"}, calls)
+	})
+
+	t.Run("skips updates when the config is unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		lb := mergeTestData1
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &lb, nil
+			},
+		}
+
+		posts := 0
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posts++
+				return "", nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+
+		assert.Equal(t, 1, posts)
+		assert.Equal(t, uint64(1), mgr.SkippedUpdates())
+
+		// a changed load balancer is applied again
+		lb = mergeTestData2
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+
+		assert.Equal(t, 2, posts)
+		assert.Equal(t, uint64(1), mgr.SkippedUpdates())
+	})
+
+	t.Run("retries updates which failed to apply", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData1, nil
+			},
+		}
+
+		postErr := errors.New("post failure") // nolint:goerr113
+		posts := 0
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posts++
+				return "", postErr
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.ErrorIs(t, mgr.updateConfigToLatest(context.Background(), false), postErr)
+
+		postErr = nil
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+		assert.Equal(t, 2, posts)
+		assert.Equal(t, uint64(0), mgr.SkippedUpdates())
+	})
+
+	t.Run("records applied configs in the history", func(t *testing.T) {
+		t.Parallel()
+
+		lb := mergeTestData1
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &lb, nil
+			},
+		}
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+			History:         history.NewStore(t.TempDir()),
+		}
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+
+		// unchanged configs are not recorded
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false, "msg-1"))
+
+		lb = mergeTestData2
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false, "msg-2", "msg-3"))
+
+		entries, err := mgr.History.List()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		assert.Empty(t, entries[0].EventIDs)
+		assert.Equal(t, []string{"msg-2", "msg-3"}, entries[1].EventIDs)
+		assert.Equal(t, "loadbal-test", entries[1].LoadBalancerID)
+
+		_, config, err := mgr.History.Get(entries[1].ID)
+		require.NoError(t, err)
+		applied, _ := mgr.AppliedConfig()
+		assert.Equal(t, applied, config)
+	})
+
+	t.Run("repairs drift of the live config", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData1, nil
+			},
+		}
+
+		posts := 0
+		live := ""
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posts++
+				live = "# _version=1\n" + config
+
+				return "", nil
+			},
+			DoGetConfig: func(ctx context.Context) (string, error) {
+				return live, nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+
+		assert.Equal(t, 1, posts)
+		assert.Equal(t, uint64(0), mgr.DriftRepairs())
+
+		// edited by hand
+		live += "\nlisten rogue\n  bind :9999\n"
+
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+
+		assert.Equal(t, 2, posts)
+		assert.Equal(t, uint64(1), mgr.DriftRepairs())
+		assert.NotContains(t, live, "rogue")
+	})
+
+	t.Run("checks drift against the config rendered from the load balancer", func(t *testing.T) {
+		t.Parallel()
+
+		var lb atomic.Pointer[lbapi.LoadBalancer]
+
+		lb.Store(&mergeTestData1)
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return lb.Load(), nil
+			},
+		}
+
+		posts := 0
+		live := ""
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posts++
+				live = config
+
+				return "", nil
+			},
+			DoGetConfig: func(ctx context.Context) (string, error) {
+				return live, nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+
+		previous := live
+
+		// a changed load balancer is applied, not repaired
+		lb.Store(&mergeTestData2)
+
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+
+		assert.Equal(t, 2, posts)
+		assert.Equal(t, uint64(0), mgr.DriftRepairs())
+
+		// rolled back by hand to the config of the previous load balancer
+		live = previous
+
+		require.NoError(t, mgr.update(reconcileRequest{verify: true}))
+
+		assert.Equal(t, 3, posts)
+		assert.Equal(t, uint64(1), mgr.DriftRepairs())
+		assert.NotEqual(t, previous, live)
+	})
+
+	t.Run("fails when a certificate cannot be uploaded", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData3, nil
+			},
+		}
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoUploadCertificate: func(ctx context.Context, name string, contents []byte) error {
+				return errors.New("storage failure") // nolint:goerr113
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			OverridesPath:   fmt.Sprintf("%s/%s", testDataBaseDir, "overrides-tls.yaml"),
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		err := mgr.updateConfigToLatest(context.Background(), false)
+		require.ErrorIs(t, err, errCertificateUploadFailure)
+	})
+}
+
+func TestApplyRollback(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	t.Run("rolls back when the reload fails", func(t *testing.T) {
+		t.Parallel()
+
+		lb := mergeTestData1
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &lb, nil
+			},
+		}
+
+		posted := []string{}
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posted = append(posted, config)
+				return fmt.Sprintf("1-%d", len(posted)), nil
+			},
+			DoWaitForReload: func(ctx context.Context, reloadID string) error {
+				if reloadID == "1-2" {
+					return errors.New("reload failed") // nolint:goerr113
+				}
+
+				return nil
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.NoError(t, mgr.updateConfigToLatest(context.Background(), false))
+
+		good, _ := mgr.AppliedConfig()
+
+		lb = mergeTestData2
+
+		require.ErrorIs(t, mgr.updateConfigToLatest(context.Background(), false), errApplyFailed)
+
+		require.Len(t, posted, 3)
+		assert.Equal(t, good, posted[2])
+		applied, _ := mgr.AppliedConfig()
+		assert.Equal(t, good, applied)
+		assert.Equal(t, uint64(1), mgr.Rollbacks())
+	})
+
+	t.Run("fails without rolling back the first apply", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData1, nil
+			},
+		}
+
+		posts := 0
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posts++
+				return "1-1", nil
+			},
+			DoWaitForReload: func(ctx context.Context, reloadID string) error {
+				return errors.New("reload failed") // nolint:goerr113
+			},
+		}
+
+		mgr := Manager{
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+		}
+
+		require.ErrorIs(t, mgr.updateConfigToLatest(context.Background(), false), errApplyFailed)
+		assert.Equal(t, 1, posts)
+		assert.Equal(t, uint64(0), mgr.Rollbacks())
+	})
+
+	t.Run("checks frontends accept connections", func(t *testing.T) {
+		t.Parallel()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer listener.Close()
+
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		closedPort := closed.Addr().(*net.TCPAddr).Port
+		closed.Close()
+
+		lb := lbapi.LoadBalancer{
+			ID: "loadbal-test",
+			Ports: lbapi.Ports{
+				Edges: []lbapi.PortEdges{
+					{Node: lbapi.PortNode{ID: "loadprt-test", Number: int64(listener.Addr().(*net.TCPAddr).Port)}},
+				},
+			},
+		}
+
+		mgr := Manager{
+			Logger:            logger,
+			DataPlaneClient:   &mock.DataplaneAPIClient{},
+			FrontendCheckHost: "127.0.0.1",
+		}
+
+		require.NoError(t, mgr.confirmApply("1-1", &lb))
+
+		lb.Ports.Edges[0].Node.Number = int64(closedPort)
+
+		require.ErrorIs(t, mgr.confirmApply("1-1", &lb), errFrontendCheckFailure)
+	})
+}
+
+func TestInitialize(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	t.Run("applies the last known good config while the lb api is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var lbAvailable atomic.Bool
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				if !lbAvailable.Load() {
+					return nil, errors.New("connection refused") // nolint:goerr113
+				}
+
+				return &mergeTestData2, nil
+			},
+		}
+
+		posted := make(chan string, 2)
+
+		mockDataplaneAPI := &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				posted <- config
+				return "", nil
+			},
+		}
+
+		stateDir := t.TempDir()
+		require.NoError(t, saveState(stateDir, "persisted config", stateMetadata{LoadBalancerID: "loadbal-test", Hash: "persisted"}))
+
+		mgr := Manager{
+			Context:         ctx,
+			Logger:          logger,
+			LBClient:        mockLBAPI,
+			DataPlaneClient: mockDataplaneAPI,
+			BaseCfgPath:     testBaseCfgPath,
+			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+			StateDir:        stateDir,
+		}
+
+		require.NoError(t, mgr.initialize())
+		assert.Equal(t, "persisted config", <-posted)
+		assert.True(t, mgr.reconciled.Load())
+
+		// lb api is back
+		lbAvailable.Store(true)
+
+		select {
+		case config := <-posted:
+			expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-2-exp.cfg"))
+			require.Nil(t, err)
+
+			assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(config))
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the desired config to be applied once the lb api is available")
+		}
+
+		// the applied config is persisted
+		assert.Eventually(t, func() bool {
+			config, meta, err := loadState(stateDir)
+
+			return err == nil && meta.LoadBalancerID == "loadbal-test" && meta.Hash != "persisted" && config != "persisted config"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	for _, notFound := range []error{lbapi.ErrLBNotfound, lbsource.ErrLoadBalancerNotFound} {
+		notFound := notFound
+
+		t.Run("does not fall back when the loadbalancer is not found: "+notFound.Error(), func(t *testing.T) {
+			t.Parallel()
+
+			mockLBAPI := &mock.LBAPIClient{
+				DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+					return nil, notFound
+				},
+			}
+
+			mockDataplaneAPI := &mock.DataplaneAPIClient{
+				DoPostConfig: func(ctx context.Context, config string) (string, error) {
+					t.Error("expected the last known good config of a deleted loadbalancer not to be applied")
+					return "", nil
+				},
+			}
+
+			stateDir := t.TempDir()
+			require.NoError(t, saveState(stateDir, "persisted config", stateMetadata{LoadBalancerID: "loadbal-test"}))
+
+			mgr := Manager{
+				Context:         context.Background(),
+				Logger:          logger,
+				LBClient:        mockLBAPI,
+				DataPlaneClient: mockDataplaneAPI,
+				BaseCfgPath:     testBaseCfgPath,
+				ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+				StateDir:        stateDir,
+			}
+
+			err := mgr.initialize()
+			require.ErrorIs(t, err, errLoadBalancerNotFound)
+			require.NotErrorIs(t, err, errLoadBalancerUnavailable)
+			assert.False(t, mgr.reconciled.Load())
+		})
+	}
+
+	t.Run("fails without a last known good config", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return nil, errors.New("connection refused") // nolint:goerr113
+			},
+		}
+
+		mgr := Manager{
+			Context:     context.Background(),
+			Logger:      logger,
+			LBClient:    mockLBAPI,
+			BaseCfgPath: testBaseCfgPath,
+			ManagedLBID: gidx.PrefixedID("loadbal-test"),
+			StateDir:    t.TempDir(),
+		}
+
+		err := mgr.initialize()
+		require.ErrorIs(t, err, errLoadBalancerUnavailable)
+		require.ErrorIs(t, err, errLastKnownGoodUnavailable)
+	})
+
+	t.Run("fails with the last known good config of another loadbalancer", func(t *testing.T) {
+		t.Parallel()
+
+		mockLBAPI := &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return nil, errors.New("connection refused") // nolint:goerr113
+			},
+		}
+
+		stateDir := t.TempDir()
+		require.NoError(t, saveState(stateDir, "persisted config", stateMetadata{LoadBalancerID: "loadbal-other"}))
+
+		mgr := Manager{
+			Context:     context.Background(),
+			Logger:      logger,
+			LBClient:    mockLBAPI,
+			BaseCfgPath: testBaseCfgPath,
+			ManagedLBID: gidx.PrefixedID("loadbal-test"),
+			StateDir:    stateDir,
+		}
+
+		require.ErrorIs(t, mgr.initialize(), errLastKnownGoodUnavailable)
+	})
+}
+
+func TestRunWatchedSource(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lb atomic.Pointer[lbapi.LoadBalancer]
+
+	lb.Store(&mergeTestData1)
+
+	watching := make(chan func(), 1)
+
+	mockLBAPI := &mock.LBWatcherClient{
+		LBAPIClient: mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return lb.Load(), nil
+			},
+		},
+		DoWatch: func(ctx context.Context, changed func()) error {
+			watching <- changed
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+	}
+
+	posted := make(chan string, 2)
+
+	mockDataplaneAPI := &mock.DataplaneAPIClient{
+		DoWaitForDataPlaneReady: func(ctx context.Context, retries int, sleep time.Duration) error {
+			return nil
+		},
+		DoCheckConfig: func(ctx context.Context, config string) error {
+			return nil
+		},
+		DoPostConfig: func(ctx context.Context, config string) (string, error) {
+			posted <- config
+			return "", nil
+		},
+	}
+
+	// no subscriber, updates are driven by the source
+	mgr := Manager{
+		Context:         ctx,
+		Logger:          logger,
+		LBClient:        mockLBAPI,
+		DataPlaneClient: mockDataplaneAPI,
+		BaseCfgPath:     testBaseCfgPath,
+		ManagedLBID:     gidx.PrefixedID("loadbal-test"),
+	}
+
+	ran := make(chan error, 1)
+
+	go func() {
+		ran <- mgr.Run()
+	}()
+
+	expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-1-exp.cfg"))
+	require.Nil(t, err)
+
+	assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(<-posted))
+
+	// the source reports a change
+	lb.Store(&mergeTestData2)
+	(<-watching)()
+
+	select {
+	case config := <-posted:
+		expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-2-exp.cfg"))
+		require.Nil(t, err)
+
+		assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(config))
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the changed load balancer to be applied")
+	}
+
+	cancel()
+
+	require.NoError(t, <-ran)
+}
+
+func TestReconciler(t *testing.T) {
+	t.Run("collapses requests made during an update", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+
+		var updates int32
+
+		r := newReconciler(func(reconcileRequest) error {
+			atomic.AddInt32(&updates, 1)
+			started <- struct{}{}
+			<-release
+
+			return nil
+		}, 0, 0)
+
+		go r.run(ctx)
+
+		first := r.request(reconcileRequest{})
+		<-started
+
+		// queued while the first update is running
+		pending := []<-chan error{}
+		for i := 0; i < 5; i++ {
+			pending = append(pending, r.request(reconcileRequest{}))
+		}
+
+		close(release)
+
+		require.NoError(t, <-first)
+
+		for _, result := range pending {
+			require.NoError(t, <-result)
+		}
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(&updates))
+	})
+
+	t.Run("coalesces a burst of requests", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var updates int32
+
+		r := newReconciler(func(reconcileRequest) error {
+			atomic.AddInt32(&updates, 1)
+			return nil
+		}, 50*time.Millisecond, time.Second)
+
+		go r.run(ctx)
+
+		results := []<-chan error{}
+
+		for i := 0; i < 5; i++ {
+			results = append(results, r.request(reconcileRequest{}))
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		for _, result := range results {
+			require.NoError(t, <-result)
+		}
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&updates))
+	})
+
+	t.Run("updates after the max wait during a steady stream", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r := newReconciler(func(reconcileRequest) error {
+			return nil
+		}, 50*time.Millisecond, 100*time.Millisecond)
+
+		go r.run(ctx)
+
+		first := r.request(reconcileRequest{})
+		start := time.Now()
+
+		// keep requesting faster than the debounce
+		done := make(chan struct{})
+
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(10 * time.Millisecond):
+					r.request(reconcileRequest{})
+				}
+			}
+		}()
+
+		require.NoError(t, <-first)
+		close(done)
+
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("returns the update error to every request", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updateErr := errors.New("update failure") // nolint:goerr113
+
+		r := newReconciler(func(reconcileRequest) error {
+			return updateErr
+		}, 0, 0)
+
+		results := []<-chan error{r.request(reconcileRequest{}), r.request(reconcileRequest{})}
+
+		go r.run(ctx)
+
+		for _, result := range results {
+			require.ErrorIs(t, <-result, updateErr)
+		}
+	})
+
+	t.Run("fails pending requests when the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		r := newReconciler(func(reconcileRequest) error {
+			return nil
+		}, 0, 0)
+
+		result := r.request(reconcileRequest{})
+
+		r.run(ctx)
+
+		require.ErrorIs(t, <-result, context.Canceled)
+	})
+}
+
+func TestPause(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	newManager := func(ctx context.Context, lb *atomic.Pointer[lbapi.LoadBalancer], posts *int32, postErr error) *Manager {
+		return &Manager{
+			Context: ctx,
+			Logger:  logger,
+			LBClient: &mock.LBAPIClient{
+				DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+					return lb.Load(), nil
+				},
+			},
+			DataPlaneClient: &mock.DataplaneAPIClient{
+				DoCheckConfig: func(ctx context.Context, config string) error {
+					return nil
+				},
+				DoPostConfig: func(ctx context.Context, config string) (string, error) {
+					atomic.AddInt32(posts, 1)
+					return "", postErr
+				},
+				DoGetConfig: func(ctx context.Context) (string, error) {
+					return "", nil
+				},
+			},
+			BaseCfgPath: testBaseCfgPath,
+			ManagedLBID: gidx.PrefixedID("loadbal-test"),
+		}
+	}
+
+	t.Run("skips automatic updates while paused", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			lb    atomic.Pointer[lbapi.LoadBalancer]
+			posts int32
+		)
+
+		lb.Store(&mergeTestData1)
+
+		mgr := newManager(ctx, &lb, &posts, nil)
+
+		require.NoError(t, mgr.reconcile(reconcileRequest{}))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+
+		config, hash := mgr.AppliedConfig()
+		assert.NotEmpty(t, config)
+		assert.Equal(t, hash, mgr.Status().AppliedHash)
+
+		mgr.Pause()
+		assert.True(t, mgr.Status().Paused)
+
+		lb.Store(&mergeTestData2)
+
+		require.NoError(t, mgr.reconcile(reconcileRequest{}))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+
+		// the update skipped while paused is applied on resume
+		mgr.Resume()
+		assert.False(t, mgr.Paused())
+
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&posts) == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("resume repairs a config rolled back while paused", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			lb    atomic.Pointer[lbapi.LoadBalancer]
+			posts int32
+		)
+
+		lb.Store(&mergeTestData1)
+
+		mgr := newManager(ctx, &lb, &posts, nil)
+
+		require.NoError(t, mgr.reconcile(reconcileRequest{}))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+
+		// no update is skipped while paused, but the live config no longer matches
+		mgr.Pause()
+		mgr.Resume()
+
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&posts) == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("manual reconcile applies right away while paused", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			lb    atomic.Pointer[lbapi.LoadBalancer]
+			posts int32
+		)
+
+		lb.Store(&mergeTestData1)
+
+		mgr := newManager(ctx, &lb, &posts, nil)
+		mgr.UpdateDebounce = time.Hour
+
+		mgr.Pause()
+
+		require.NoError(t, mgr.Reconcile())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+	})
+
+	t.Run("records the last error", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			lb    atomic.Pointer[lbapi.LoadBalancer]
+			posts int32
+		)
+
+		lb.Store(&mergeTestData1)
+
+		postErr := errors.New("post failed")
+		mgr := newManager(ctx, &lb, &posts, postErr)
+
+		require.ErrorIs(t, mgr.Reconcile(), postErr)
+
+		st := mgr.Status()
+		require.NotNil(t, st.LastError)
+		assert.Contains(t, st.LastError.Message, postErr.Error())
+		assert.Nil(t, st.LastAppliedAt)
+	})
+
+	t.Run("reports the dataplaneapi endpoints of a fleet", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Implements(t, (*endpointReporter)(nil), &dataplaneapi.Fleet{})
+
+		endpoints := []dataplaneapi.EndpointStatus{
+			{URL: "http://haproxy-0:5555/v2/", Applied: 2},
+			{URL: "http://haproxy-1:5555/v2/", Applied: 1, Failed: 1, LastError: "reload failed"},
+		}
+
+		mgr := &Manager{
+			Logger: logger,
+			DataPlaneClient: &mock.FleetClient{
+				DoEndpoints: func() []dataplaneapi.EndpointStatus {
+					return endpoints
+				},
+			},
+		}
+
+		assert.Equal(t, endpoints, mgr.Status().Endpoints)
+
+		mgr.DataPlaneClient = &mock.DataplaneAPIClient{}
+		assert.Empty(t, mgr.Status().Endpoints)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	// fixture is a manager of its own per subtest, with the lb api and haproxy it talks to
+	type fixture struct {
+		mgr        *Manager
+		lb         atomic.Pointer[lbapi.LoadBalancer]
+		lbErr      error
+		live       string
+		failReload bool
+	}
+
+	newFixture := func() *fixture {
+		f := &fixture{}
+		f.lb.Store(&mergeTestData1)
+
+		f.mgr = &Manager{
+			Context: context.Background(),
+			Logger:  logger,
+			LBClient: &mock.LBAPIClient{
+				DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+					return f.lb.Load(), f.lbErr
+				},
 			},
-			DoCheckConfig: func(ctx context.Context, config string) error {
-				return nil
+			DataPlaneClient: &mock.DataplaneAPIClient{
+				DoCheckConfig: func(ctx context.Context, config string) error {
+					return nil
+				},
+				DoPostConfig: func(ctx context.Context, config string) (string, error) {
+					f.live = "# _version=1\n" + config
+					return "1-1", nil
+				},
+				DoWaitForReload: func(ctx context.Context, reloadID string) error {
+					if f.failReload {
+						f.failReload = false
+						return errors.New("reload failed") // nolint:goerr113
+					}
+
+					return nil
+				},
+				DoGetConfig: func(ctx context.Context) (string, error) {
+					return f.live, nil
+				},
 			},
+			BaseCfgPath: testBaseCfgPath,
+			ManagedLBID: gidx.PrefixedID("loadbal-test"),
 		}
 
-		mgr := Manager{
-			Logger:          logger,
-			LBClient:        mockLBAPI,
-			DataPlaneClient: mockDataplaneAPI,
-			BaseCfgPath:     testBaseCfgPath,
-			ManagedLBID:     gidx.PrefixedID("loadbal-test"),
-		}
+		return f
+	}
 
-		err := mgr.updateConfigToLatest()
-		require.Nil(t, err)
+	// the metrics are global, so the subtests do not run in parallel and only check what their own
+	// updates added
+	t.Run("observes the fetch, render and apply of an update", func(t *testing.T) {
+		f := newFixture()
 
-		expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-1-exp.cfg"))
-		require.Nil(t, err)
+		fetches := sampleCount(t, metrics.LBAPIRequestDuration)
+		renders := sampleCount(t, metrics.RenderDuration)
+		lastSuccess := testutil.ToFloat64(metrics.LastApplySuccess)
+		start := time.Now()
+
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+
+		assert.Equal(t, fetches+1, sampleCount(t, metrics.LBAPIRequestDuration))
+		assert.Equal(t, renders+1, sampleCount(t, metrics.RenderDuration))
+		assert.GreaterOrEqual(t, testutil.ToFloat64(metrics.LastApplySuccess), max(lastSuccess, float64(start.Unix())))
+	})
+
+	t.Run("counts the failed fetches", func(t *testing.T) {
+		f := newFixture()
+		f.lbErr = errors.New("lbapi unavailable") // nolint:goerr113
+
+		failures := testutil.ToFloat64(metrics.LBAPIErrorsTotal)
+
+		require.ErrorIs(t, f.mgr.update(reconcileRequest{}), errLoadBalancerUnavailable)
+		assert.Equal(t, failures+1, testutil.ToFloat64(metrics.LBAPIErrorsTotal))
+	})
+
+	t.Run("counts the updates skipped as unchanged", func(t *testing.T) {
+		f := newFixture()
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+
+		unchanged := metrics.UpdatesSkippedTotal.WithLabelValues(metrics.UpdateSkippedUnchanged)
+		skipped := testutil.ToFloat64(unchanged)
+
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+		assert.Equal(t, skipped+1, testutil.ToFloat64(unchanged))
+	})
 
-		assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(mgr.currentConfig))
+	t.Run("counts the updates skipped while paused", func(t *testing.T) {
+		f := newFixture()
+		f.mgr.Pause()
+
+		paused := metrics.UpdatesSkippedTotal.WithLabelValues(metrics.UpdateSkippedPaused)
+		skipped := testutil.ToFloat64(paused)
+
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+		assert.Equal(t, skipped+1, testutil.ToFloat64(paused))
+	})
+
+	t.Run("counts the drift repairs", func(t *testing.T) {
+		f := newFixture()
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+
+		repairs := testutil.ToFloat64(metrics.DriftRepairsTotal)
+
+		// edited by hand
+		f.live += "\nlisten rogue\n  bind :9999\n"
+
+		require.NoError(t, f.mgr.update(reconcileRequest{verify: true}))
+		assert.Equal(t, repairs+1, testutil.ToFloat64(metrics.DriftRepairsTotal))
+		assert.NotContains(t, f.live, "rogue")
+	})
+
+	t.Run("counts the rollbacks", func(t *testing.T) {
+		f := newFixture()
+		require.NoError(t, f.mgr.update(reconcileRequest{}))
+
+		rollbacks := testutil.ToFloat64(metrics.RollbacksTotal)
+
+		f.lb.Store(&mergeTestData2)
+		f.failReload = true
+
+		require.ErrorIs(t, f.mgr.update(reconcileRequest{}), errApplyFailed)
+		assert.Equal(t, rollbacks+1, testutil.ToFloat64(metrics.RollbacksTotal))
 	})
 }
 
+// sampleCount returns the number of observations of a histogram
+func sampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	m := &dto.Metric{}
+	require.NoError(t, h.Write(m))
+
+	return m.GetHistogram().GetSampleCount()
+}
+
 func TestLoadBalancerTargeted(t *testing.T) {
 	l, _ := zap.NewDevelopmentConfig().Build()
 	logger := l.Sugar()
@@ -362,6 +1972,8 @@ func TestProcessMsg(t *testing.T) {
 		tt := tt
 
 		t.Run(tt.name, func(t *testing.T) {
+			filtered := testutil.ToFloat64(metrics.EventsTotal.WithLabelValues(metrics.EventFiltered))
+
 			msg := PublishTestMessage(t, context.Background(), eventsConn, tt.pubsubMsg)
 			err := mgr.ProcessMsg(msg)
 
@@ -373,6 +1985,7 @@ func TestProcessMsg(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
+			assert.Equal(t, filtered+1, testutil.ToFloat64(metrics.EventsTotal.WithLabelValues(metrics.EventFiltered)))
 		})
 	}
 
@@ -381,8 +1994,8 @@ func TestProcessMsg(t *testing.T) {
 			DoCheckConfig: func(ctx context.Context, config string) error {
 				return nil
 			},
-			DoPostConfig: func(ctx context.Context, config string) error {
-				return nil
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
 			},
 		}
 
@@ -409,7 +2022,177 @@ func TestProcessMsg(t *testing.T) {
 
 		err = mgr.ProcessMsg(msg)
 		require.Nil(t, err)
+
+		require.NotNil(t, mgr.Status().LastEvent)
+		assert.Equal(t, msg.ID(), mgr.Status().LastEvent.ID)
+	})
+}
+
+func TestProcessMsgTracing(t *testing.T) {
+	l, err := zap.NewDevelopmentConfig().Build()
+	logger := l.Sugar()
+
+	require.Nil(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// testnats server connection
+	natsSrv, err := eventtools.NewNatsServer()
+	require.NoError(t, err)
+
+	eventsConn, err := events.NewNATSConnection(natsSrv.Config.NATS)
+	require.NoError(t, err)
+
+	defer func() {
+		natsSrv.Close()
+
+		_ = eventsConn.Shutdown(context.Background())
+	}()
+
+	mgr := &Manager{
+		Context: context.Background(),
+		Logger:  logger,
+		DataPlaneClient: &mock.DataplaneAPIClient{
+			DoCheckConfig: func(ctx context.Context, config string) error {
+				return nil
+			},
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "reload-1", nil
+			},
+		},
+		LBClient: &mock.LBAPIClient{
+			DoGetLoadBalancer: func(ctx context.Context, id string) (*lbapi.LoadBalancer, error) {
+				return &mergeTestData1, nil
+			},
+		},
+		BaseCfgPath: testBaseCfgPath,
+		ManagedLBID: gidx.PrefixedID("loadbal-test"),
+	}
+
+	// the trace of the service publishing the event
+	ctx, publish := otel.Tracer("test").Start(context.Background(), "publish")
+
+	msg := PublishTestMessage(t, ctx, eventsConn, events.ChangeMessage{
+		SubjectID: gidx.PrefixedID("loadbal-test"),
+		EventType: string(events.CreateChangeType),
 	})
+
+	publish.End()
+
+	require.NoError(t, mgr.ProcessMsg(msg))
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	for _, name := range []string{
+		"manager.ProcessMsg",
+		"manager.reconcile",
+		"lbapi.GetLoadBalancer",
+		"manager.mergeConfig",
+		"dataplaneapi.CheckConfig",
+		"dataplaneapi.PostConfig",
+	} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, publish.SpanContext().TraceID(), spans[name].SpanContext().TraceID(), name)
+	}
+
+	assert.Equal(t, spans["manager.ProcessMsg"].SpanContext().SpanID(), spans["manager.reconcile"].Parent().SpanID())
+	assert.Equal(t, spans["manager.reconcile"].SpanContext().SpanID(), spans["dataplaneapi.PostConfig"].Parent().SpanID())
+}
+
+func TestHealth(t *testing.T) {
+	testcases := []struct {
+		name        string
+		subscriber  *mock.Subscriber
+		apiReady    bool
+		reconciled  bool
+		expLiveErr  error
+		expReadyErr []error
+	}{
+		{
+			name:       "ready",
+			subscriber: &mock.Subscriber{},
+			apiReady:   true,
+			reconciled: true,
+		},
+		{
+			name:       "ready without subscriber",
+			apiReady:   true,
+			reconciled: true,
+		},
+		{
+			name:        "not reconciled yet",
+			subscriber:  &mock.Subscriber{},
+			apiReady:    true,
+			expReadyErr: []error{errNotReconciled},
+		},
+		{
+			name:        "dataplaneapi unreachable",
+			subscriber:  &mock.Subscriber{},
+			reconciled:  true,
+			expReadyErr: []error{errDataPlaneUnavailable},
+		},
+		{
+			name: "subscriptions not active yet",
+			subscriber: &mock.Subscriber{
+				DoListening: func() bool { return false },
+			},
+			apiReady:    true,
+			expReadyErr: []error{errSubscriberNotListening, errNotReconciled},
+		},
+		{
+			name: "subscriber stopped",
+			subscriber: &mock.Subscriber{
+				DoListening: func() bool { return false },
+				DoAlive:     func() bool { return false },
+			},
+			apiReady:    true,
+			reconciled:  true,
+			expLiveErr:  errSubscriberStopped,
+			expReadyErr: []error{errSubscriberNotListening},
+		},
+	}
+
+	for _, tt := range testcases {
+		// go vet
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mgr := &Manager{
+				DataPlaneClient: &mock.DataplaneAPIClient{
+					DoAPIIsReady: func(ctx context.Context) bool {
+						return tt.apiReady
+					},
+				},
+			}
+
+			if tt.subscriber != nil {
+				mgr.Subscriber = tt.subscriber
+			}
+
+			mgr.reconciled.Store(tt.reconciled)
+
+			assert.ErrorIs(t, mgr.Live(), tt.expLiveErr)
+
+			err := mgr.Ready(context.Background())
+			if len(tt.expReadyErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			for _, expErr := range tt.expReadyErr {
+				assert.ErrorIs(t, err, expErr)
+			}
+		})
+	}
 }
 
 func TestEventsIntegration(t *testing.T) {
@@ -436,8 +2219,8 @@ func TestEventsIntegration(t *testing.T) {
 			DoCheckConfig: func(ctx context.Context, config string) error {
 				return nil
 			},
-			DoPostConfig: func(ctx context.Context, config string) error {
-				return nil
+			DoPostConfig: func(ctx context.Context, config string) (string, error) {
+				return "", nil
 			},
 		}
 
@@ -532,13 +2315,14 @@ func TestEventsIntegration(t *testing.T) {
 		// wait for msg to be processed by manager
 		time.Sleep(1 * time.Second)
 
-		// check currentConfig (testing helper variable)
-		assert.NotEmpty(t, mgr.currentConfig)
+		// check the applied config
+		applied, _ := mgr.AppliedConfig()
+		assert.NotEmpty(t, applied)
 
 		expCfg, err := os.ReadFile(fmt.Sprintf("%s/%s", testDataBaseDir, "lb-ex-1-exp.cfg"))
 		require.Nil(t, err)
 
-		assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(mgr.currentConfig))
+		assert.Equal(t, strings.TrimSpace(string(expCfg)), strings.TrimSpace(applied))
 	})
 }
 
@@ -560,7 +2344,6 @@ var mergeTestData1 = lbapi.LoadBalancer{
 		Edges: []lbapi.PortEdges{
 			{
 				Node: lbapi.PortNode{
-					// TODO - @rizzza - AddressFamily?
 					ID:     "loadprt-test",
 					Name:   "ssh-service",
 					Number: 22,
@@ -618,7 +2401,6 @@ var mergeTestData2 = lbapi.LoadBalancer{
 		Edges: []lbapi.PortEdges{
 			{
 				Node: lbapi.PortNode{
-					// TODO - @rizzza - AddressFamily?
 					ID:     "loadprt-test",
 					Name:   "ssh-service-a",
 					Number: 22,
@@ -695,7 +2477,6 @@ var mergeTestData3 = lbapi.LoadBalancer{
 		Edges: []lbapi.PortEdges{
 			{
 				Node: lbapi.PortNode{
-					// TODO - @rizzza - AddressFamily?
 					ID:     "loadprt-testhttp",
 					Name:   "http",
 					Number: 80,
@@ -724,13 +2505,12 @@ var mergeTestData3 = lbapi.LoadBalancer{
 			},
 			{
 				Node: lbapi.PortNode{
-					// TODO - @rizzza - AddressFamily?
 					ID:     "loadprt-testhttps",
 					Name:   "https",
 					Number: 443,
 					Pools: []lbapi.Pool{
 						{
-							ID:       "loadpol-test",
+							ID:       "loadpol-testhttps",
 							Name:     "ssh-service-a",
 							Protocol: "tcp",
 							Origins: lbapi.Origins{
@@ -754,3 +2534,134 @@ var mergeTestData3 = lbapi.LoadBalancer{
 		},
 	},
 }
+
+var mergeTestData4 = lbapi.LoadBalancer{
+	ID:   "loadbal-test",
+	Name: "ipv6 and fqdn",
+	Ports: lbapi.Ports{
+		Edges: []lbapi.PortEdges{
+			{
+				Node: lbapi.PortNode{
+					ID:     "loadprt-test",
+					Name:   "http",
+					Number: 80,
+					Pools: []lbapi.Pool{
+						{
+							ID:       "loadpol-test",
+							Name:     "web",
+							Protocol: "tcp",
+							Origins: lbapi.Origins{
+								Edges: []lbapi.OriginEdges{
+									{
+										Node: lbapi.OriginNode{
+											ID:         "loadogn-test1",
+											Name:       "svr1",
+											Target:     "2001:db8::1",
+											PortNumber: 8080,
+											Weight:     50,
+											Active:     true,
+										},
+									},
+									{
+										Node: lbapi.OriginNode{
+											ID:         "loadogn-test2",
+											Name:       "svr2",
+											Target:     "origin.example.com",
+											PortNumber: 8080,
+											Weight:     50,
+											Active:     true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+var mergeTestData5 = lbapi.LoadBalancer{
+	ID:   "loadbal-test",
+	Name: "shared pool",
+	Ports: lbapi.Ports{
+		Edges: []lbapi.PortEdges{
+			{
+				Node: lbapi.PortNode{
+					ID:     "loadprt-testa",
+					Name:   "service-a",
+					Number: 8080,
+					Pools: []lbapi.Pool{
+						{
+							ID:       "loadpol-shared",
+							Name:     "shared",
+							Protocol: "tcp",
+							Origins: lbapi.Origins{
+								Edges: []lbapi.OriginEdges{
+									{
+										Node: lbapi.OriginNode{
+											ID:         "loadogn-test1",
+											Name:       "svr1",
+											Target:     "1.2.3.4",
+											PortNumber: 8080,
+											Weight:     10,
+											Active:     true,
+										},
+									},
+								},
+							},
+						},
+						{
+							ID:       "loadpol-other",
+							Name:     "other",
+							Protocol: "tcp",
+							Origins: lbapi.Origins{
+								Edges: []lbapi.OriginEdges{
+									{
+										Node: lbapi.OriginNode{
+											ID:         "loadogn-test1",
+											Name:       "svr1",
+											Target:     "1.2.3.4",
+											PortNumber: 8080,
+											Weight:     30,
+											Active:     true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Node: lbapi.PortNode{
+					ID:     "loadprt-testb",
+					Name:   "service-b",
+					Number: 8081,
+					Pools: []lbapi.Pool{
+						{
+							ID:       "loadpol-shared",
+							Name:     "shared",
+							Protocol: "tcp",
+							Origins: lbapi.Origins{
+								Edges: []lbapi.OriginEdges{
+									{
+										Node: lbapi.OriginNode{
+											ID:         "loadogn-test1",
+											Name:       "svr1",
+											Target:     "1.2.3.4",
+											PortNumber: 8080,
+											Weight:     10,
+											Active:     true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+}
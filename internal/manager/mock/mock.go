@@ -5,6 +5,8 @@ import (
 	"time"
 
 	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
 )
 
 // LBAPIClient mock client
@@ -16,18 +18,44 @@ func (c LBAPIClient) GetLoadBalancer(ctx context.Context, id string) (*lbapi.Loa
 	return c.DoGetLoadBalancer(ctx, id)
 }
 
+// LBWatcherClient mock client of a load balancer source reporting its changes
+type LBWatcherClient struct {
+	LBAPIClient
+	DoWatch func(ctx context.Context, changed func()) error
+}
+
+func (c LBWatcherClient) Watch(ctx context.Context, changed func()) error {
+	return c.DoWatch(ctx, changed)
+}
+
 // DataplaneAPIClient mock client
 type DataplaneAPIClient struct {
-	DoPostConfig            func(ctx context.Context, config string) error
+	DoPostConfig            func(ctx context.Context, config string) (string, error)
+	DoWaitForReload         func(ctx context.Context, reloadID string) error
+	DoGetConfig             func(ctx context.Context) (string, error)
 	DoCheckConfig           func(ctx context.Context, config string) error
+	DoUploadCertificate     func(ctx context.Context, name string, contents []byte) error
 	DoAPIIsReady            func(ctx context.Context) bool
 	DoWaitForDataPlaneReady func(ctx context.Context, retries int, sleep time.Duration) error
 }
 
-func (c *DataplaneAPIClient) PostConfig(ctx context.Context, config string) error {
+func (c *DataplaneAPIClient) PostConfig(ctx context.Context, config string) (string, error) {
 	return c.DoPostConfig(ctx, config)
 }
 
+// WaitForReload reports reloads as successful unless DoWaitForReload is set
+func (c *DataplaneAPIClient) WaitForReload(ctx context.Context, reloadID string) error {
+	if c.DoWaitForReload == nil {
+		return nil
+	}
+
+	return c.DoWaitForReload(ctx, reloadID)
+}
+
+func (c DataplaneAPIClient) GetConfig(ctx context.Context) (string, error) {
+	return c.DoGetConfig(ctx)
+}
+
 func (c DataplaneAPIClient) APIIsReady(ctx context.Context) bool {
 	return c.DoAPIIsReady(ctx)
 }
@@ -36,15 +64,31 @@ func (c DataplaneAPIClient) CheckConfig(ctx context.Context, config string) erro
 	return c.DoCheckConfig(ctx, config)
 }
 
+func (c DataplaneAPIClient) UploadCertificate(ctx context.Context, name string, contents []byte) error {
+	return c.DoUploadCertificate(ctx, name, contents)
+}
+
 func (c DataplaneAPIClient) WaitForDataPlaneReady(ctx context.Context, retries int, sleep time.Duration) error {
 	return c.DoWaitForDataPlaneReady(ctx, retries, sleep)
 }
 
+// FleetClient mock client of a fleet of dataplaneapi endpoints
+type FleetClient struct {
+	DataplaneAPIClient
+	DoEndpoints func() []dataplaneapi.EndpointStatus
+}
+
+func (c *FleetClient) Endpoints() []dataplaneapi.EndpointStatus {
+	return c.DoEndpoints()
+}
+
 // Subscriber mock client
 type Subscriber struct {
 	DoClose     func() error
 	DoSubscribe func(subject string) error
 	DoListen    func() error
+	DoListening func() bool
+	DoAlive     func() bool
 }
 
 func (s *Subscriber) Close() error {
@@ -58,3 +102,19 @@ func (s *Subscriber) Subscribe(subject string) error {
 func (s *Subscriber) Listen() error {
 	return s.DoListen()
 }
+
+func (s *Subscriber) Listening() bool {
+	if s.DoListening == nil {
+		return true
+	}
+
+	return s.DoListening()
+}
+
+func (s *Subscriber) Alive() bool {
+	if s.DoAlive == nil {
+		return true
+	}
+
+	return s.DoAlive()
+}
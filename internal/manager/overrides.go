@@ -0,0 +1,526 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HealthCheckType is the kind of health check run against the origins of a pool
+type HealthCheckType string
+
+const (
+	// HealthCheckTCP checks that a TCP connection to the origin can be established
+	HealthCheckTCP HealthCheckType = "tcp"
+
+	// HealthCheckHTTP sends an HTTP request to the origin and checks the response status
+	HealthCheckHTTP HealthCheckType = "http"
+
+	// HealthCheckNone disables health checks
+	HealthCheckNone HealthCheckType = "none"
+)
+
+// BalanceAlgorithm is the load balancing algorithm of a pool
+type BalanceAlgorithm string
+
+const (
+	// BalanceRoundRobin distributes connections to each origin in turn, according to their weights
+	BalanceRoundRobin BalanceAlgorithm = "roundrobin"
+
+	// BalanceLeastConn sends connections to the origin with the fewest active connections
+	BalanceLeastConn BalanceAlgorithm = "leastconn"
+
+	// BalanceSource hashes the client source address to choose an origin
+	BalanceSource BalanceAlgorithm = "source"
+
+	// BalanceHash consistently hashes a sample expression (the source address by default) to choose an origin
+	BalanceHash BalanceAlgorithm = "hash"
+)
+
+// PersistenceType is the kind of session persistence of a pool
+type PersistenceType string
+
+const (
+	// PersistenceCookie pins HTTP clients to an origin with a cookie inserted in the response
+	PersistenceCookie PersistenceType = "cookie"
+
+	// PersistenceStickTable pins clients to an origin by their source address, usable for TCP services
+	PersistenceStickTable PersistenceType = "stick-table"
+)
+
+// PortMode is the proxy mode of the frontend of a port
+type PortMode string
+
+const (
+	// PortModeTCP proxies connections at layer 4
+	PortModeTCP PortMode = "tcp"
+
+	// PortModeHTTP proxies HTTP requests, allowing requests to be routed by host and path
+	PortModeHTTP PortMode = "http"
+)
+
+// TLSVersion is a TLS protocol version as named by HAProxy
+type TLSVersion string
+
+const (
+	// TLSVersion10 is TLS 1.0
+	TLSVersion10 TLSVersion = "TLSv1.0"
+
+	// TLSVersion11 is TLS 1.1
+	TLSVersion11 TLSVersion = "TLSv1.1"
+
+	// TLSVersion12 is TLS 1.2, the default minimum version
+	TLSVersion12 TLSVersion = "TLSv1.2"
+
+	// TLSVersion13 is TLS 1.3
+	TLSVersion13 TLSVersion = "TLSv1.3"
+)
+
+// Overrides are local port, pool and origin settings which are not (yet) provided by the
+// load balancer api, keyed by port, pool and origin ID
+type Overrides struct {
+	Ports   map[string]PortOverride   `yaml:"ports"`
+	Pools   map[string]PoolOverride   `yaml:"pools"`
+	Origins map[string]OriginOverride `yaml:"origins"`
+}
+
+// PortOverride are the settings applied to the frontend of a port
+type PortOverride struct {
+	Mode PortMode `yaml:"mode"`
+	// Routes send HTTP requests to a pool by host and path, in order. Requests not matching
+	// any route are split between the pools no route points to.
+	Routes []Route `yaml:"routes"`
+	// TLS terminates TLS on the port
+	TLS *TLS `yaml:"tls"`
+}
+
+// TLS configures TLS termination on the frontend of a port
+type TLS struct {
+	// Certificates are local PEM files holding a certificate, its chain and its key. They are
+	// uploaded to the Data Plane API storage under their file name, which must be unique, and
+	// the certificate matching the SNI of the client is served, the first one without SNI.
+	Certificates []string `yaml:"certificates"`
+	// MinVersion is the minimum TLS version accepted, defaults to TLSv1.2
+	MinVersion TLSVersion `yaml:"minVersion"`
+	// ALPN are the protocols advertised to clients, defaults to h2 and http/1.1
+	ALPN []string `yaml:"alpn"`
+}
+
+// Route sends the HTTP requests matching all of its set conditions to a pool of the port
+type Route struct {
+	Host       string `yaml:"host"`
+	PathPrefix string `yaml:"pathPrefix"`
+	Pool       string `yaml:"pool"`
+}
+
+// PoolOverride are the settings applied to the backend of a pool and the servers of its origins
+type PoolOverride struct {
+	// TODO: read health checks from the pool once the lb api schema has them, they are only
+	// set through the overrides until then.
+	HealthCheck *HealthCheck `yaml:"healthCheck"`
+
+	// TODO: read the balance algorithm and persistence from the pool once the lb api schema has
+	// them, they are only set through the overrides until then.
+	Balance     *Balance     `yaml:"balance"`
+	Persistence *Persistence `yaml:"persistence"`
+
+	TLS *OriginTLS `yaml:"tls"`
+}
+
+// OriginTLS encrypts the connections of a pool to its origins, verifying their certificates
+type OriginTLS struct {
+	// CA is a local PEM bundle of the certificate authorities origin certificates are verified against
+	CA string `yaml:"ca"`
+	// SNI is the server name sent to origins, and which their certificates are verified for
+	SNI string `yaml:"sni"`
+	// ClientCertificate is a local PEM file with the certificate and key presented to origins
+	ClientCertificate string `yaml:"clientCertificate"`
+}
+
+// OriginOverride are the settings applied to the server of a single origin, taking precedence over the pool settings
+type OriginOverride struct {
+	HealthCheck *HealthCheck `yaml:"healthCheck"`
+}
+
+// HealthCheck configures how origins are health checked. The type and http settings only
+// apply at pool level, as HAProxy configures them on the backend, origins can only set the type to none.
+type HealthCheck struct {
+	Type     HealthCheckType `yaml:"type"`
+	Port     int64           `yaml:"port"`
+	Interval string          `yaml:"interval"`
+	Rise     int64           `yaml:"rise"`
+	Fall     int64           `yaml:"fall"`
+	HTTP     *HTTPCheck      `yaml:"http"`
+	Agent    *AgentCheck     `yaml:"agent"`
+}
+
+// HTTPCheck configures the request sent by an http health check and the expected response
+type HTTPCheck struct {
+	Method       string `yaml:"method"`
+	Path         string `yaml:"path"`
+	ExpectStatus string `yaml:"expectStatus"`
+}
+
+// AgentCheck configures an agent check run alongside the regular health check
+type AgentCheck struct {
+	Port     int64  `yaml:"port"`
+	Interval string `yaml:"interval"`
+}
+
+// Balance configures how a pool distributes connections between its origins
+type Balance struct {
+	Algorithm BalanceAlgorithm `yaml:"algorithm"`
+	// HashKey is the sample expression hashed by the hash algorithm, defaults to src
+	HashKey string `yaml:"hashKey"`
+	// Consistent uses consistent hashing for the source algorithm, limiting redistribution when origins change
+	Consistent bool `yaml:"consistent"`
+}
+
+// Persistence configures session persistence of a pool. Cookie persistence requires the ports the
+// pool is attached to be in http mode.
+type Persistence struct {
+	Type PersistenceType `yaml:"type"`
+	// CookieName is the name of the inserted cookie, defaults to SERVERID
+	CookieName string `yaml:"cookieName"`
+	// Expire is how long an idle stick-table entry is kept, defaults to 30m
+	Expire string `yaml:"expire"`
+	// Size is the maximum number of stick-table entries, defaults to 100k
+	Size string `yaml:"size"`
+}
+
+// LoadOverrides reads the overrides from a YAML (or JSON) file
+func LoadOverrides(path string) (*Overrides, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &Overrides{}
+
+	if err := yaml.Unmarshal(contents, overrides); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", errOverridesInvalid, path, err)
+	}
+
+	if err := overrides.validate(); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", errOverridesInvalid, path, err)
+	}
+
+	return overrides, nil
+}
+
+func (o *Overrides) validate() error {
+	for id, port := range o.Ports {
+		if err := port.validate(); err != nil {
+			return fmt.Errorf("port %s: %w", id, err)
+		}
+	}
+
+	// certificates are stored by file name, two different files can't share one
+	certificates := map[string]string{}
+
+	for _, path := range o.certificates() {
+		name := filepath.Base(path)
+		if other, ok := certificates[name]; ok {
+			return fmt.Errorf("%w: %s and %s", errCertificateNameConflict, other, path)
+		}
+
+		certificates[name] = path
+	}
+
+	for id, pool := range o.Pools {
+		if err := pool.HealthCheck.validate(); err != nil {
+			return fmt.Errorf("pool %s: %w", id, err)
+		}
+
+		if err := pool.Balance.validate(); err != nil {
+			return fmt.Errorf("pool %s: %w", id, err)
+		}
+
+		if err := pool.Persistence.validate(); err != nil {
+			return fmt.Errorf("pool %s: %w", id, err)
+		}
+
+		if err := pool.TLS.validate(); err != nil {
+			return fmt.Errorf("pool %s: %w", id, err)
+		}
+	}
+
+	for id, origin := range o.Origins {
+		if err := origin.HealthCheck.validateOrigin(); err != nil {
+			return fmt.Errorf("origin %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// validateModes checks the pool overrides against the mode of the ports each pool is attached to,
+// only known once merged with a load balancer
+func (o *Overrides) validateModes(modes map[string]PortMode) error {
+	for id, mode := range modes {
+		persistence := o.pool(id).Persistence
+		if persistence != nil && persistence.Type == PersistenceCookie && mode != PortModeHTTP {
+			return newLabelError(id, errPersistenceRequiresHTTP, fmt.Errorf("attached to a %s mode port", mode)) //nolint:goerr113
+		}
+	}
+
+	return nil
+}
+
+// port returns the overrides for a port, or empty overrides when none are set
+func (o *Overrides) port(id string) PortOverride {
+	if o == nil {
+		return PortOverride{}
+	}
+
+	return o.Ports[id]
+}
+
+// pool returns the overrides for a pool, or empty overrides when none are set
+func (o *Overrides) pool(id string) PoolOverride {
+	if o == nil {
+		return PoolOverride{}
+	}
+
+	return o.Pools[id]
+}
+
+// origin returns the overrides for an origin, or empty overrides when none are set
+func (o *Overrides) origin(id string) OriginOverride {
+	if o == nil {
+		return OriginOverride{}
+	}
+
+	return o.Origins[id]
+}
+
+// certificates returns the paths of the certificates of all ports and the CA bundles and client
+// certificates of all pools, sorted and without duplicates
+func (o *Overrides) certificates() []string {
+	if o == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	paths := []string{}
+
+	add := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, port := range o.Ports {
+		if port.TLS == nil {
+			continue
+		}
+
+		for _, path := range port.TLS.Certificates {
+			add(path)
+		}
+	}
+
+	for _, pool := range o.Pools {
+		if pool.TLS == nil {
+			continue
+		}
+
+		add(pool.TLS.CA)
+		add(pool.TLS.ClientCertificate)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// mode returns the proxy mode of the port, tcp unless set
+func (p PortOverride) mode() PortMode {
+	if p.Mode == "" {
+		return PortModeTCP
+	}
+
+	return p.Mode
+}
+
+func (p PortOverride) validate() error {
+	switch p.mode() {
+	case PortModeTCP:
+		if len(p.Routes) > 0 {
+			return errRoutesRequireHTTP
+		}
+	case PortModeHTTP:
+	default:
+		return fmt.Errorf("%w: %q", errPortModeInvalid, p.Mode)
+	}
+
+	for i, route := range p.Routes {
+		if route.Pool == "" || (route.Host == "" && route.PathPrefix == "") {
+			return fmt.Errorf("route %d: %w", i, errRouteInvalid)
+		}
+	}
+
+	return p.TLS.validate()
+}
+
+func (t *TLS) validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if len(t.Certificates) == 0 {
+		return errTLSCertificatesRequired
+	}
+
+	switch t.MinVersion {
+	case "", TLSVersion10, TLSVersion11, TLSVersion12, TLSVersion13:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errTLSVersionInvalid, t.MinVersion)
+	}
+}
+
+func (t *OriginTLS) validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if t.CA == "" {
+		return errOriginTLSCARequired
+	}
+
+	return nil
+}
+
+// minVersion returns the minimum TLS version, TLSv1.2 unless set
+func (t *TLS) minVersion() TLSVersion {
+	if t.MinVersion == "" {
+		return TLSVersion12
+	}
+
+	return t.MinVersion
+}
+
+// alpn returns the advertised ALPN protocols, h2 and http/1.1 unless set
+func (t *TLS) alpn() []string {
+	if len(t.ALPN) == 0 {
+		return []string{"h2", "http/1.1"}
+	}
+
+	return t.ALPN
+}
+
+func (hc *HealthCheck) validate() error {
+	if hc == nil {
+		return nil
+	}
+
+	switch hc.Type {
+	case "", HealthCheckTCP, HealthCheckHTTP, HealthCheckNone:
+	default:
+		return fmt.Errorf("%w: %q", errHealthCheckTypeInvalid, hc.Type)
+	}
+
+	if hc.Port < 0 || hc.Rise < 0 || hc.Fall < 0 {
+		return errHealthCheckValueInvalid
+	}
+
+	if hc.Agent != nil && hc.Agent.Port <= 0 {
+		return errAgentCheckPortRequired
+	}
+
+	return nil
+}
+
+// validateOrigin validates the health check of an origin, which can only disable the check type
+// of its pool, as the type and http settings are configured on the backend
+func (hc *HealthCheck) validateOrigin() error {
+	if hc == nil {
+		return nil
+	}
+
+	switch hc.Type {
+	case "", HealthCheckNone:
+	default:
+		return fmt.Errorf("%w: %q", errHealthCheckTypePoolOnly, hc.Type)
+	}
+
+	if hc.HTTP != nil {
+		return errHealthCheckHTTPPoolOnly
+	}
+
+	return hc.validate()
+}
+
+func (b *Balance) validate() error {
+	if b == nil {
+		return nil
+	}
+
+	switch b.Algorithm {
+	case "", BalanceRoundRobin, BalanceLeastConn, BalanceSource, BalanceHash:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errBalanceAlgorithmInvalid, b.Algorithm)
+	}
+}
+
+func (p *Persistence) validate() error {
+	if p == nil {
+		return nil
+	}
+
+	switch p.Type {
+	case PersistenceCookie, PersistenceStickTable:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errPersistenceTypeInvalid, p.Type)
+	}
+}
+
+// merge returns the health check with the non-zero settings of other taking precedence
+func (hc *HealthCheck) merge(other *HealthCheck) *HealthCheck {
+	if hc == nil {
+		return other
+	}
+
+	merged := *hc
+
+	if other == nil {
+		return &merged
+	}
+
+	if other.Type != "" {
+		merged.Type = other.Type
+	}
+
+	if other.Port != 0 {
+		merged.Port = other.Port
+	}
+
+	if other.Interval != "" {
+		merged.Interval = other.Interval
+	}
+
+	if other.Rise != 0 {
+		merged.Rise = other.Rise
+	}
+
+	if other.Fall != 0 {
+		merged.Fall = other.Fall
+	}
+
+	if other.HTTP != nil {
+		merged.HTTP = other.HTTP
+	}
+
+	if other.Agent != nil {
+		merged.Agent = other.Agent
+	}
+
+	return &merged
+}
@@ -0,0 +1,198 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// reconciler serializes config updates through a single worker. Requests made while an update
+// is running collapse into one pending update, which starts after the running one and so fetches
+// the newest load balancer state, ensuring the last applied config reflects it.
+//
+// With a debounce, an update waits until no request was made for the debounce duration, but no
+// longer than maxWait after the first request, coalescing bursts of events into a single update.
+//
+// The update is asked to verify the live config when any of the requests it covers asked for it.
+type reconciler struct {
+	update   func(req reconcileRequest) error
+	debounce time.Duration
+	maxWait  time.Duration
+
+	mu      sync.Mutex
+	waiters []chan error
+	pending reconcileRequest
+
+	// wake signals the worker that an update is pending
+	wake chan struct{}
+}
+
+// reconcileRequest describes why an update is requested
+type reconcileRequest struct {
+	// verify compares the live config with the one rendered from the load balancer, repairing drift
+	verify bool
+
+	// eventIDs are the IDs of the event messages which triggered the update
+	eventIDs []string
+
+	// spans are the span contexts of the event messages which triggered the update
+	spans []trace.SpanContext
+
+	// manual updates are requested by an operator, they start right away and apply even when paused
+	manual bool
+}
+
+// merge returns a request covering both r and other
+func (r reconcileRequest) merge(other reconcileRequest) reconcileRequest {
+	return reconcileRequest{
+		verify:   r.verify || other.verify,
+		eventIDs: append(r.eventIDs, other.eventIDs...),
+		spans:    append(r.spans, other.spans...),
+		manual:   r.manual || other.manual,
+	}
+}
+
+// newReconciler returns a reconciler applying updates with the update func
+func newReconciler(update func(req reconcileRequest) error, debounce, maxWait time.Duration) *reconciler {
+	return &reconciler{
+		update:   update,
+		debounce: debounce,
+		maxWait:  maxWait,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// request queues an update and returns a channel receiving its result
+func (r *reconciler) request(req reconcileRequest) <-chan error {
+	result := make(chan error, 1)
+
+	r.mu.Lock()
+	r.waiters = append(r.waiters, result)
+	r.pending = r.pending.merge(req)
+	r.mu.Unlock()
+
+	// a wake-up already pending picks up this request as well
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return result
+}
+
+// run applies the pending updates one at a time until the context is done
+func (r *reconciler) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			r.flush(ctx.Err())
+			return
+		case <-r.wake:
+			// never start an update once shutting down
+			if !r.settle(ctx) || ctx.Err() != nil {
+				r.flush(ctx.Err())
+				return
+			}
+
+			waiters, req := r.take()
+			if len(waiters) == 0 {
+				continue
+			}
+
+			err := r.update(req)
+
+			for _, w := range waiters {
+				w <- err
+			}
+		}
+	}
+}
+
+// settle waits for the debounce window of a pending update to pass, returning false if the context is done first
+func (r *reconciler) settle(ctx context.Context) bool {
+	if r.debounce <= 0 || r.manual() {
+		return true
+	}
+
+	var deadline <-chan time.Time
+
+	if r.maxWait > 0 {
+		maxWait := time.NewTimer(r.maxWait)
+		defer maxWait.Stop()
+
+		deadline = maxWait.C
+	}
+
+	for {
+		quiet := time.NewTimer(r.debounce)
+
+		select {
+		case <-ctx.Done():
+			quiet.Stop()
+			return false
+		case <-r.wake:
+			// another request, restart the window
+			quiet.Stop()
+
+			if r.manual() {
+				return true
+			}
+		case <-quiet.C:
+			return true
+		case <-deadline:
+			quiet.Stop()
+			return true
+		}
+	}
+}
+
+// manual returns true when the pending update was requested by an operator
+func (r *reconciler) manual() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pending.manual
+}
+
+// take returns the waiters and the request of the pending update, and clears them
+func (r *reconciler) take() ([]chan error, reconcileRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	waiters, req := r.waiters, r.pending
+	r.waiters, r.pending = nil, reconcileRequest{}
+
+	return waiters, req
+}
+
+// flush fails the pending requests with err
+func (r *reconciler) flush(err error) {
+	waiters, _ := r.take()
+
+	for _, w := range waiters {
+		w <- err
+	}
+}
+
+// reconcile queues a config update on the single writer and waits for its result
+func (m *Manager) reconcile(req reconcileRequest) error {
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	m.reconcilerOnce.Do(func() {
+		m.reconciler = newReconciler(m.update, m.UpdateDebounce, m.UpdateMaxWait)
+
+		go m.reconciler.run(ctx)
+	})
+
+	select {
+	case err := <-m.reconciler.request(req):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
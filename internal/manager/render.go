@@ -0,0 +1,675 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v4"
+	"github.com/haproxytech/config-parser/v4/common"
+	"github.com/haproxytech/config-parser/v4/params"
+	"github.com/haproxytech/config-parser/v4/parsers/actions"
+	httpActions "github.com/haproxytech/config-parser/v4/parsers/http/actions"
+	"github.com/haproxytech/config-parser/v4/types"
+
+	lbapi "go.infratographer.com/load-balancer-api/pkg/client"
+)
+
+const (
+	// dnsResolversName is the name of the resolvers section used to resolve FQDN origins
+	dnsResolversName = "dns"
+
+	// defaultPersistenceCookie is the name of the cookie used for cookie based session persistence
+	defaultPersistenceCookie = "SERVERID"
+
+	// defaultStickTableSize is the maximum number of entries of a persistence stick-table
+	defaultStickTableSize = "100k"
+
+	// defaultStickTableExpire is how long idle persistence stick-table entries are kept
+	defaultStickTableExpire = "30m"
+
+	// DefaultCertificatesDir is the directory the dataplaneapi stores uploaded ssl certificates in
+	DefaultCertificatesDir = "/etc/haproxy/ssl"
+)
+
+// mergeOptions are the optional settings applied while merging the lb api response into the base config
+type mergeOptions struct {
+	addressFamily   AddressFamily
+	overrides       *Overrides
+	certificatesDir string
+}
+
+// mergeOption configures a mergeOptions setting
+type mergeOption func(o *mergeOptions)
+
+// withAddressFamily sets the address family frontend ports are bound on
+func withAddressFamily(af AddressFamily) mergeOption {
+	return func(o *mergeOptions) {
+		if af != "" {
+			o.addressFamily = af
+		}
+	}
+}
+
+// withOverrides sets the local pool and origin overrides
+func withOverrides(overrides *Overrides) mergeOption {
+	return func(o *mergeOptions) {
+		o.overrides = overrides
+	}
+}
+
+// withCertificatesDir sets the directory uploaded certificates are referenced from
+func withCertificatesDir(dir string) mergeOption {
+	return func(o *mergeOptions) {
+		if dir != "" {
+			o.certificatesDir = dir
+		}
+	}
+}
+
+// mergeConfig takes the response from lb api, merges with the base haproxy config and returns it
+func mergeConfig(cfg parser.Parser, lb *lbapi.LoadBalancer, opts ...mergeOption) (parser.Parser, error) {
+	mopts := mergeOptions{
+		addressFamily:   AddressFamilyIPv4,
+		certificatesDir: DefaultCertificatesDir,
+	}
+
+	for _, opt := range opts {
+		opt(&mopts)
+	}
+
+	modes, err := poolModes(lb, mopts.overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mopts.overrides.validateModes(modes); err != nil {
+		return nil, err
+	}
+
+	// servers already rendered per backend, a pool attached to several ports is rendered once
+	backends := map[string]map[string]bool{}
+
+	for _, p := range lb.Ports.Edges {
+		if err := addFrontend(cfg, p.Node, mopts); err != nil {
+			return nil, err
+		}
+
+		for _, pool := range p.Node.Pools {
+			if err := addBackend(cfg, pool, modes[pool.ID], backends, mopts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// poolModes returns the proxy mode of the backend of each pool, which must match the mode of every port it is attached to
+func poolModes(lb *lbapi.LoadBalancer, overrides *Overrides) (map[string]PortMode, error) {
+	modes := map[string]PortMode{}
+
+	for _, p := range lb.Ports.Edges {
+		mode := overrides.port(p.Node.ID).mode()
+
+		for _, pool := range p.Node.Pools {
+			if existing, ok := modes[pool.ID]; ok && existing != mode {
+				return nil, newLabelError(pool.ID, errPoolModeConflict, fmt.Errorf("%s and %s", existing, mode)) //nolint:goerr113
+			}
+
+			modes[pool.ID] = mode
+		}
+	}
+
+	return modes, nil
+}
+
+// addFrontend creates the frontend for a port and maps it to the backends of its pools
+func addFrontend(cfg parser.Parser, port lbapi.PortNode, mopts mergeOptions) error {
+	portOverride := mopts.overrides.port(port.ID)
+
+	if err := cfg.SectionsCreate(parser.Frontends, port.ID); err != nil {
+		return newLabelError(port.ID, errFrontendSectionLabelFailure, err)
+	}
+
+	for _, bind := range frontendBinds(mopts.addressFamily, port.Number, portOverride.TLS, mopts.certificatesDir) {
+		if err := cfg.Insert(parser.Frontends, port.ID, "bind", bind); err != nil {
+			return newAttrError(errFrontendBindFailure, err)
+		}
+	}
+
+	if portOverride.mode() == PortModeHTTP {
+		proto := "http"
+		if portOverride.TLS != nil {
+			proto = "https"
+		}
+
+		if err := addHTTPFrontend(cfg, port.ID, proto); err != nil {
+			return err
+		}
+	}
+
+	rules, err := addRoutes(cfg, port, portOverride.Routes)
+	if err != nil {
+		return err
+	}
+
+	// map frontend to backends
+	for _, rule := range rules {
+		if err := cfg.Insert(parser.Frontends, port.ID, "use_backend", rule); err != nil {
+			return newAttrError(errUseBackendFailure, err)
+		}
+	}
+
+	return nil
+}
+
+// addHTTPFrontend switches the frontend of a port to http mode and adds the X-Forwarded-For and X-Forwarded-Proto headers
+func addHTTPFrontend(cfg parser.Parser, frontend string, proto string) error {
+	attrs := []struct {
+		name string
+		data common.ParserData
+	}{
+		{"mode", types.StringC{Value: string(PortModeHTTP)}},
+		{"option httplog", types.OptionHTTPLog{}},
+		{"option forwardfor", types.OptionForwardFor{}},
+		{"http-request", &httpActions.SetHeader{Name: "X-Forwarded-Proto", Fmt: proto}},
+	}
+
+	for _, attr := range attrs {
+		if err := cfg.Set(parser.Frontends, frontend, attr.name, attr.data); err != nil {
+			return newAttrError(errFrontendAttrFailure, err)
+		}
+	}
+
+	return nil
+}
+
+// addRoutes adds the acls of the host and path routes of a port and returns its use_backend rules:
+// the routes, in order, followed by the weighted selection between the pools no route points to
+func addRoutes(cfg parser.Parser, port lbapi.PortNode, routes []Route) ([]types.UseBackend, error) {
+	pools := map[string]bool{}
+	for _, pool := range port.Pools {
+		pools[pool.ID] = true
+	}
+
+	rules := []types.UseBackend{}
+	routed := map[string]bool{}
+
+	for i, route := range routes {
+		if !pools[route.Pool] {
+			return nil, newLabelError(route.Pool, errRoutePoolNotFound, fmt.Errorf("port %s", port.ID)) //nolint:goerr113
+		}
+
+		acls := []types.ACL{}
+
+		if route.Host != "" {
+			acls = append(acls, types.ACL{
+				Name:      fmt.Sprintf("route%d_host", i),
+				Criterion: "hdr(host),field(1,:)",
+				Value:     "-i " + route.Host,
+			})
+		}
+
+		if route.PathPrefix != "" {
+			acls = append(acls, types.ACL{
+				Name:      fmt.Sprintf("route%d_path", i),
+				Criterion: "path_beg",
+				Value:     route.PathPrefix,
+			})
+		}
+
+		names := []string{}
+
+		for _, acl := range acls {
+			if err := cfg.Insert(parser.Frontends, port.ID, "acl", acl); err != nil {
+				return nil, newAttrError(errFrontendAttrFailure, err)
+			}
+
+			names = append(names, acl.Name)
+		}
+
+		rules = append(rules, types.UseBackend{
+			Name:     route.Pool,
+			Cond:     "if",
+			CondTest: strings.Join(names, " "),
+		})
+
+		routed[route.Pool] = true
+	}
+
+	unrouted := []lbapi.Pool{}
+
+	for _, pool := range port.Pools {
+		if !routed[pool.ID] {
+			unrouted = append(unrouted, pool)
+		}
+	}
+
+	// every pool has a route, send requests matching none of them to any pool
+	if len(unrouted) == 0 {
+		unrouted = port.Pools
+	}
+
+	return append(rules, poolSelection(unrouted)...), nil
+}
+
+// addBackend creates the backend for a pool and adds a server for each of its origins
+func addBackend(cfg parser.Parser, pool lbapi.Pool, mode PortMode, backends map[string]map[string]bool, mopts mergeOptions) error {
+	poolOverride := mopts.overrides.pool(pool.ID)
+
+	servers, ok := backends[pool.ID]
+	if !ok {
+		if err := cfg.SectionsCreate(parser.Backends, pool.ID); err != nil {
+			return newLabelError(pool.ID, errBackendSectionLabelFailure, err)
+		}
+
+		if mode == PortModeHTTP {
+			if err := cfg.Set(parser.Backends, pool.ID, "mode", types.StringC{Value: string(PortModeHTTP)}); err != nil {
+				return newAttrError(errBackendAttrFailure, err)
+			}
+		}
+
+		if err := addBalance(cfg, pool.ID, poolOverride.Balance); err != nil {
+			return err
+		}
+
+		if err := addPersistence(cfg, pool.ID, poolOverride.Persistence, mopts.addressFamily); err != nil {
+			return err
+		}
+
+		if err := addHTTPCheck(cfg, pool.ID, poolOverride.HealthCheck); err != nil {
+			return err
+		}
+
+		servers = map[string]bool{}
+		backends[pool.ID] = servers
+	}
+
+	for _, origin := range pool.Origins.Edges {
+		name := fmt.Sprintf("%s::%s", origin.Node.ID, origin.Node.Target)
+		if servers[name] {
+			continue
+		}
+
+		target := classifyTarget(origin.Node.Target)
+		if target == targetInvalid {
+			return newLabelError(origin.Node.Target, errOriginTargetInvalid, errOriginTargetUnsupported)
+		}
+
+		healthCheck := poolOverride.HealthCheck.merge(mopts.overrides.origin(origin.Node.ID).HealthCheck)
+
+		srvAddr := serverAddress(origin.Node.Target, origin.Node.PortNumber)
+		srvAddr += serverCheckParams(healthCheck, origin.Node.PortNumber)
+		srvAddr += fmt.Sprintf(" weight %d", origin.Node.Weight)
+		srvAddr += serverTLSParams(poolOverride.TLS, healthCheck, mopts.certificatesDir)
+
+		if target == targetFQDN {
+			if err := ensureResolvers(cfg); err != nil {
+				return err
+			}
+
+			// re-resolve hostnames at runtime, and start the server even if the name does not resolve yet
+			srvAddr += fmt.Sprintf(" resolvers %s init-addr libc,none", dnsResolversName)
+		}
+
+		if poolOverride.Persistence != nil && poolOverride.Persistence.Type == PersistenceCookie {
+			srvAddr += fmt.Sprintf(" cookie %s", origin.Node.ID)
+		}
+
+		if !origin.Node.Active {
+			srvAddr += " disabled"
+		}
+
+		srvr := types.Server{
+			Name:    name,
+			Address: srvAddr,
+		}
+
+		if err := cfg.Set(parser.Backends, pool.ID, "server", srvr); err != nil {
+			return newLabelError(pool.ID, errBackendServerFailure, err)
+		}
+
+		servers[name] = true
+	}
+
+	return nil
+}
+
+// addBalance sets the load balancing algorithm of the backend of a pool, HAProxy defaults to roundrobin
+func addBalance(cfg parser.Parser, backend string, balance *Balance) error {
+	if balance == nil || balance.Algorithm == "" {
+		return nil
+	}
+
+	data := types.Balance{Algorithm: string(balance.Algorithm)}
+	consistent := balance.Consistent
+
+	if balance.Algorithm == BalanceHash {
+		key := balance.HashKey
+		if key == "" {
+			key = "src"
+		}
+
+		data.Params = &params.BalanceHash{Expression: key}
+		consistent = true
+	}
+
+	if err := cfg.Set(parser.Backends, backend, "balance", data); err != nil {
+		return newAttrError(errBackendAttrFailure, err)
+	}
+
+	if !consistent {
+		return nil
+	}
+
+	if err := cfg.Set(parser.Backends, backend, "hash-type", types.HashType{Method: "consistent"}); err != nil {
+		return newAttrError(errBackendAttrFailure, err)
+	}
+
+	return nil
+}
+
+// addPersistence configures session persistence on the backend of a pool
+func addPersistence(cfg parser.Parser, backend string, persistence *Persistence, af AddressFamily) error {
+	if persistence == nil {
+		return nil
+	}
+
+	switch persistence.Type {
+	case PersistenceCookie:
+		name := persistence.CookieName
+		if name == "" {
+			name = defaultPersistenceCookie
+		}
+
+		if err := cfg.Set(parser.Backends, backend, "cookie", types.Cookie{
+			Name:     name,
+			Type:     "insert",
+			Indirect: true,
+			Nocache:  true,
+		}); err != nil {
+			return newAttrError(errBackendAttrFailure, err)
+		}
+	case PersistenceStickTable:
+		table := types.StickTable{
+			Type:   "ip",
+			Size:   persistence.Size,
+			Expire: persistence.Expire,
+		}
+
+		// ipv6 tables also store IPv4 clients, as IPv4-mapped addresses
+		if af != AddressFamilyIPv4 {
+			table.Type = "ipv6"
+		}
+
+		if table.Size == "" {
+			table.Size = defaultStickTableSize
+		}
+
+		if table.Expire == "" {
+			table.Expire = defaultStickTableExpire
+		}
+
+		if err := cfg.Set(parser.Backends, backend, "stick-table", table); err != nil {
+			return newAttrError(errBackendAttrFailure, err)
+		}
+
+		if err := cfg.Insert(parser.Backends, backend, "stick", types.Stick{Type: "on", Pattern: "src"}); err != nil {
+			return newAttrError(errBackendAttrFailure, err)
+		}
+	}
+
+	return nil
+}
+
+// addHTTPCheck configures the backend of a pool to run http health checks
+func addHTTPCheck(cfg parser.Parser, backend string, hc *HealthCheck) error {
+	if hc == nil || hc.Type != HealthCheckHTTP {
+		return nil
+	}
+
+	httpCheck := HTTPCheck{}
+	if hc.HTTP != nil {
+		httpCheck = *hc.HTTP
+	}
+
+	if httpCheck.Method == "" {
+		httpCheck.Method = http.MethodGet
+	}
+
+	if httpCheck.Path == "" {
+		httpCheck.Path = "/"
+	}
+
+	if err := cfg.Set(parser.Backends, backend, "option httpchk", types.OptionHttpchk{
+		Method: httpCheck.Method,
+		URI:    httpCheck.Path,
+	}); err != nil {
+		return newAttrError(errBackendAttrFailure, err)
+	}
+
+	if httpCheck.ExpectStatus == "" {
+		return nil
+	}
+
+	if err := cfg.Insert(parser.Backends, backend, "http-check", &actions.CheckExpect{
+		Match:   "status",
+		Pattern: httpCheck.ExpectStatus,
+	}); err != nil {
+		return newAttrError(errBackendAttrFailure, err)
+	}
+
+	return nil
+}
+
+// serverCheckParams returns the health check options of a server line, by default a tcp check on the origin port
+func serverCheckParams(hc *HealthCheck, originPort int64) string {
+	if hc == nil {
+		hc = &HealthCheck{}
+	}
+
+	opts := ""
+
+	if hc.Type != HealthCheckNone {
+		port := originPort
+		if hc.Port != 0 {
+			port = hc.Port
+		}
+
+		opts += fmt.Sprintf(" check port %d", port)
+
+		if hc.Interval != "" {
+			opts += fmt.Sprintf(" inter %s", hc.Interval)
+		}
+
+		if hc.Rise != 0 {
+			opts += fmt.Sprintf(" rise %d", hc.Rise)
+		}
+
+		if hc.Fall != 0 {
+			opts += fmt.Sprintf(" fall %d", hc.Fall)
+		}
+	}
+
+	if hc.Agent != nil {
+		opts += fmt.Sprintf(" agent-check agent-port %d", hc.Agent.Port)
+
+		if hc.Agent.Interval != "" {
+			opts += fmt.Sprintf(" agent-inter %s", hc.Agent.Interval)
+		}
+	}
+
+	return opts
+}
+
+// serverTLSParams returns the server options encrypting the connections and health checks to an
+// origin, verifying its certificate against the CA bundle in certsDir
+func serverTLSParams(tls *OriginTLS, hc *HealthCheck, certsDir string) string {
+	if tls == nil {
+		return ""
+	}
+
+	opts := fmt.Sprintf(" ssl verify required ca-file %s", path.Join(certsDir, filepath.Base(tls.CA)))
+
+	if tls.SNI != "" {
+		opts += fmt.Sprintf(" sni str(%s)", tls.SNI)
+	}
+
+	if tls.ClientCertificate != "" {
+		opts += fmt.Sprintf(" crt %s", path.Join(certsDir, filepath.Base(tls.ClientCertificate)))
+	}
+
+	// checks are sent to an explicit port, which haproxy does not encrypt unless asked to
+	if hc == nil || hc.Type != HealthCheckNone {
+		opts += " check-ssl"
+
+		if tls.SNI != "" {
+			opts += fmt.Sprintf(" check-sni %s", tls.SNI)
+		}
+	}
+
+	return opts
+}
+
+// poolSelection returns the use_backend rules choosing between the pools of a port. Traffic is
+// split proportionally to the total weight of each pool's active origins, keyed on a hash of the
+// client source address, so the same client is consistently sent to the same pool.
+func poolSelection(pools []lbapi.Pool) []types.UseBackend {
+	if len(pools) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(pools)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	var total int64
+	for _, pool := range sorted {
+		total += poolWeight(pool)
+	}
+
+	if len(sorted) == 1 || total == 0 {
+		return []types.UseBackend{{Name: sorted[0].ID}}
+	}
+
+	rules := []types.UseBackend{}
+
+	var upper int64
+
+	for _, pool := range sorted {
+		weight := poolWeight(pool)
+		if weight == 0 {
+			continue
+		}
+
+		upper += weight
+
+		if upper == total {
+			rules = append(rules, types.UseBackend{Name: pool.ID})
+			break
+		}
+
+		rules = append(rules, types.UseBackend{
+			Name:     pool.ID,
+			Cond:     "if",
+			CondTest: fmt.Sprintf("{ src,crc32(1),mod(%d) lt %d }", total, upper),
+		})
+	}
+
+	return rules
+}
+
+// poolWeight returns the sum of the weights of the active origins in a pool
+func poolWeight(pool lbapi.Pool) int64 {
+	var weight int64
+
+	for _, origin := range pool.Origins.Edges {
+		if origin.Node.Active && origin.Node.Weight > 0 {
+			weight += origin.Node.Weight
+		}
+	}
+
+	return weight
+}
+
+// ensureResolvers creates the resolvers section used by FQDN origins, unless it already exists
+func ensureResolvers(cfg parser.Parser) error {
+	sections, err := cfg.SectionsGet(parser.Resolvers)
+	if err == nil {
+		for _, section := range sections {
+			if section == dnsResolversName {
+				return nil
+			}
+		}
+	}
+
+	if err := cfg.SectionsCreate(parser.Resolvers, dnsResolversName); err != nil {
+		return newLabelError(dnsResolversName, errResolversSectionLabelFailure, err)
+	}
+
+	attrs := []struct {
+		name string
+		data common.ParserData
+	}{
+		{"parse-resolv-conf", types.Enabled{}},
+		{"resolve_retries", types.StringC{Value: "3"}},
+		{"timeout resolve", types.SimpleTimeout{Value: "1s"}},
+		{"timeout retry", types.SimpleTimeout{Value: "1s"}},
+		{"hold valid", types.StringC{Value: "10s"}},
+	}
+
+	for _, attr := range attrs {
+		if err := cfg.Set(parser.Resolvers, dnsResolversName, attr.name, attr.data); err != nil {
+			return newAttrError(errResolversAttrFailure, err)
+		}
+	}
+
+	return nil
+}
+
+// frontendBinds returns the bind attributes for a port in the requested address family,
+// terminating TLS with the certificates in certsDir when tls is set
+func frontendBinds(af AddressFamily, port int64, tls *TLS, certsDir string) []types.Bind {
+	ipv4 := types.Bind{
+		Path:   fmt.Sprintf("ipv4@:%d", port),
+		Params: tlsBindOptions(tls, certsDir),
+	}
+	ipv6 := types.Bind{
+		Path:   fmt.Sprintf("ipv6@:%d", port),
+		Params: append([]params.BindOption{&params.BindOptionWord{Name: "v6only"}}, tlsBindOptions(tls, certsDir)...),
+	}
+
+	switch af {
+	case AddressFamilyIPv6:
+		return []types.Bind{ipv6}
+	case AddressFamilyDual:
+		return []types.Bind{ipv4, ipv6}
+	default:
+		return []types.Bind{ipv4}
+	}
+}
+
+// tlsBindOptions returns the bind options terminating TLS, haproxy serves the certificate
+// matching the SNI sent by the client and the first certificate when none match
+func tlsBindOptions(tls *TLS, certsDir string) []params.BindOption {
+	if tls == nil {
+		return nil
+	}
+
+	opts := []params.BindOption{&params.BindOptionWord{Name: "ssl"}}
+
+	for _, cert := range tls.Certificates {
+		opts = append(opts, &params.BindOptionValue{Name: "crt", Value: path.Join(certsDir, filepath.Base(cert))})
+	}
+
+	return append(opts,
+		&params.BindOptionValue{Name: "alpn", Value: strings.Join(tls.alpn(), ",")},
+		&params.BindOptionValue{Name: "ssl-min-ver", Value: string(tls.minVersion())},
+	)
+}
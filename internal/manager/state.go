@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/atomicfile"
+)
+
+const (
+	// stateConfigFile is the file name of the persisted last known good config
+	stateConfigFile = "haproxy.cfg"
+
+	// stateMetadataFile is the file name of the metadata of the persisted config
+	stateMetadataFile = "metadata.json"
+)
+
+// stateMetadata describes the last known good config persisted to the state dir
+type stateMetadata struct {
+	LoadBalancerID string    `json:"loadBalancerID"`
+	Hash           string    `json:"hash"`
+	AppliedAt      time.Time `json:"appliedAt"`
+}
+
+// saveState persists a successfully applied config and its metadata to dir
+func saveState(dir string, config string, meta stateMetadata) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the config goes first, metadata only ever describes a config fully written
+	if err := atomicfile.Write(filepath.Join(dir, stateConfigFile), []byte(config)); err != nil {
+		return err
+	}
+
+	return atomicfile.Write(filepath.Join(dir, stateMetadataFile), contents)
+}
+
+// loadState reads the last known good config and its metadata from dir
+func loadState(dir string) (string, stateMetadata, error) {
+	meta := stateMetadata{}
+
+	contents, err := os.ReadFile(filepath.Join(dir, stateMetadataFile))
+	if err != nil {
+		return "", meta, err
+	}
+
+	if err := json.Unmarshal(contents, &meta); err != nil {
+		return "", meta, err
+	}
+
+	config, err := os.ReadFile(filepath.Join(dir, stateConfigFile))
+	if err != nil {
+		return "", meta, err
+	}
+
+	return string(config), meta, nil
+}
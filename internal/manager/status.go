@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/dataplaneapi"
+)
+
+// Status is a snapshot of what the manager last did
+type Status struct {
+	Paused        bool         `json:"paused"`
+	AppliedHash   string       `json:"appliedHash,omitempty"`
+	LastAppliedAt *time.Time   `json:"lastAppliedAt,omitempty"`
+	LastEvent     *EventStatus `json:"lastEvent,omitempty"`
+	LastError     *ErrorStatus `json:"lastError,omitempty"`
+
+	// Endpoints is the outcome of the applies to each dataplaneapi endpoint of a fleet
+	Endpoints []dataplaneapi.EndpointStatus `json:"endpoints,omitempty"`
+}
+
+// EventStatus describes an event message processed by the manager
+type EventStatus struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	SubjectID  string    `json:"subjectID"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+// ErrorStatus describes an update which failed
+type ErrorStatus struct {
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// status records what the manager last did, read concurrently with the updates
+type status struct {
+	mu sync.Mutex
+
+	appliedConfig string
+	appliedHash   string
+	lastAppliedAt time.Time
+	lastEvent     *EventStatus
+	lastError     *ErrorStatus
+}
+
+// applied records a config successfully applied
+func (s *status) applied(config, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appliedConfig = config
+	s.appliedHash = hash
+	s.lastAppliedAt = time.Now().UTC()
+}
+
+// received records an event message targeted to the load balancer
+func (s *status) received(event EventStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastEvent = &event
+}
+
+// failed records an update which failed
+func (s *status) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError = &ErrorStatus{Message: err.Error(), At: time.Now().UTC()}
+}
+
+// Status returns a snapshot of the manager status
+func (m *Manager) Status() Status {
+	m.status.mu.Lock()
+	defer m.status.mu.Unlock()
+
+	st := Status{
+		Paused:      m.paused.Load(),
+		AppliedHash: m.status.appliedHash,
+		LastEvent:   m.status.lastEvent,
+		LastError:   m.status.lastError,
+	}
+
+	if !m.status.lastAppliedAt.IsZero() {
+		appliedAt := m.status.lastAppliedAt
+		st.LastAppliedAt = &appliedAt
+	}
+
+	if r, ok := m.DataPlaneClient.(endpointReporter); ok {
+		st.Endpoints = r.Endpoints()
+	}
+
+	return st
+}
+
+// AppliedConfig returns the last config successfully applied and its hash, empty until one is applied
+func (m *Manager) AppliedConfig() (string, string) {
+	m.status.mu.Lock()
+	defer m.status.mu.Unlock()
+
+	return m.status.appliedConfig, m.status.appliedHash
+}
+
+// Reconcile updates the haproxy config right away, without waiting for the debounce window and
+// even while applies are paused, repairing drift of the live config
+func (m *Manager) Reconcile() error {
+	return m.reconcile(reconcileRequest{verify: true, manual: true})
+}
+
+// Pause stops applying configs on events, source changes and resyncs until Resume is called
+func (m *Manager) Pause() {
+	if !m.paused.Swap(true) {
+		m.Logger.Warnw("automatic config applies paused", zap.String("loadbalancerID", m.ManagedLBID.String()))
+	}
+}
+
+// Resume applies configs automatically again, reconciling right away. The live config is
+// verified even when no update was skipped while paused, since it may have been rolled back
+// meanwhile.
+func (m *Manager) Resume() {
+	if !m.paused.Swap(false) {
+		return
+	}
+
+	m.Logger.Infow("automatic config applies resumed", zap.String("loadbalancerID", m.ManagedLBID.String()))
+
+	go func() {
+		if err := m.reconcile(reconcileRequest{verify: true}); err != nil {
+			m.Logger.Errorw("failed to update haproxy config after resuming", zap.String("loadbalancerID", m.ManagedLBID.String()), zap.Error(err))
+		}
+	}()
+}
+
+// Paused returns true while automatic config applies are paused
+func (m *Manager) Paused() bool {
+	return m.paused.Load()
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxFQDNLength is the maximum length of a fully qualified domain name
+	maxFQDNLength = 253
+
+	// maxFQDNLabelLength is the maximum length of a single label of a domain name
+	maxFQDNLabelLength = 63
+)
+
+// targetType is the kind of address an origin target is
+type targetType int
+
+const (
+	targetInvalid targetType = iota
+	targetIPv4
+	targetIPv6
+	targetFQDN
+)
+
+// classifyTarget returns whether the origin target is an IPv4 literal, IPv6 literal or a DNS hostname
+func classifyTarget(target string) targetType {
+	if ip := net.ParseIP(target); ip != nil {
+		if ip.To4() != nil {
+			return targetIPv4
+		}
+
+		return targetIPv6
+	}
+
+	if isFQDN(target) {
+		return targetFQDN
+	}
+
+	return targetInvalid
+}
+
+// isFQDN returns true if the target is a syntactically valid DNS hostname
+func isFQDN(target string) bool {
+	name := strings.TrimSuffix(target, ".")
+
+	if name == "" || len(name) > maxFQDNLength {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+
+	// a name made of only digits and dots is a malformed IPv4 literal, not a hostname
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return false
+	}
+
+	for _, label := range labels {
+		if label == "" || len(label) > maxFQDNLabelLength {
+			return false
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !isHostnameRune(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isHostnameRune(r rune) bool {
+	return r == '-' ||
+		(r >= 'a' && r <= 'z') ||
+		(r >= 'A' && r <= 'Z') ||
+		(r >= '0' && r <= '9')
+}
+
+// serverAddress returns the address:port of an origin for a server line, IPv6 literals are bracketed
+func serverAddress(target string, port int64) string {
+	return net.JoinHostPort(target, strconv.FormatInt(port, 10))
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the manager with the global tracer provider
+var tracer = otel.Tracer("go.infratographer.com/loadbalancer-manager-haproxy/internal/manager")
+
+// startReconcileSpan starts the span of an update, child of the span of the first event message it
+// covers and linked to the spans of the messages coalesced into it
+func (m *Manager) startReconcileSpan(req reconcileRequest) (context.Context, trace.Span) {
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.String("loadbalancer.id", m.ManagedLBID.String()),
+			attribute.StringSlice("event.message.ids", req.eventIDs),
+			attribute.Bool("verify", req.verify),
+		),
+	}
+
+	if len(req.spans) > 0 {
+		ctx = trace.ContextWithSpanContext(ctx, req.spans[0])
+
+		for _, sc := range req.spans[1:] {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+
+	return tracer.Start(ctx, "manager.reconcile", opts...)
+}
+
+// recordError marks span as failed with err, if any
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan records err on span and ends it
+func endSpan(span trace.Span, err error) {
+	recordError(span, err)
+	span.End()
+}
@@ -0,0 +1,2 @@
+// Package metrics provides the prometheus metrics of the manager
+package metrics
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "loadbalancer_manager_haproxy"
+
+// event message results
+const (
+	EventReceived   = "received"
+	EventFiltered   = "filtered"
+	EventAcked      = "acked"
+	EventNaked      = "naked"
+	EventTerminated = "terminated"
+)
+
+// reasons an update was skipped
+const (
+	UpdateSkippedPaused    = "paused"
+	UpdateSkippedUnchanged = "unchanged"
+)
+
+var (
+	// EventsTotal counts the event messages by result
+	EventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Event messages received, filtered as not targeting the load balancer, acked, naked and terminated.",
+	}, []string{"result"})
+
+	// LBAPIRequestDuration observes the load balancer fetches
+	LBAPIRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lbapi_request_duration_seconds",
+		Help:      "Duration of the load balancer fetches from the load balancer source.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// LBAPIErrorsTotal counts the failed load balancer fetches
+	LBAPIErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lbapi_errors_total",
+		Help:      "Load balancer fetches from the load balancer source which failed.",
+	})
+
+	// RenderDuration observes the haproxy config renders
+	RenderDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "render_duration_seconds",
+		Help:      "Duration of merging a load balancer and the overrides into the base haproxy config.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+
+	// DataPlaneRequestDuration observes the dataplaneapi requests by operation and status code
+	DataPlaneRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dataplaneapi_request_duration_seconds",
+		Help:      "Duration of the dataplaneapi requests by operation and response status code, error when no response was received.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "code"})
+
+	// UpdatesSkippedTotal counts the updates which applied nothing by reason
+	UpdatesSkippedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_skipped_total",
+		Help:      "Updates skipped as automatic applies were paused or the rendered config did not change.",
+	}, []string{"reason"})
+
+	// DriftRepairsTotal counts the live configs found drifted and applied again
+	DriftRepairsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drift_repairs_total",
+		Help:      "Live haproxy configs found drifted from the config rendered from the load balancer, which were applied again.",
+	})
+
+	// RollbacksTotal counts the failed applies rolled back to the last applied config
+	RollbacksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rollbacks_total",
+		Help:      "Config applies which failed and were rolled back to the last applied config.",
+	})
+
+	// LastApplySuccess is the time a config was last applied
+	LastApplySuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_apply_success_timestamp_seconds",
+		Help:      "Time a config was last applied to haproxy.",
+	})
+
+	// lastApply is the unix time in nanoseconds a config was last applied, or the manager started
+	lastApply atomic.Int64
+)
+
+func init() {
+	lastApply.Store(time.Now().UnixNano())
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "seconds_since_last_apply_success",
+		Help:      "Seconds since a config was last applied to haproxy, or the manager started when none was.",
+	}, func() float64 {
+		return time.Since(time.Unix(0, lastApply.Load())).Seconds()
+	})
+}
+
+// ObserveApplySuccess records a config was applied at t
+func ObserveApplySuccess(t time.Time) {
+	lastApply.Store(t.UnixNano())
+	LastApplySuccess.Set(float64(t.UnixNano()) / float64(time.Second))
+}
+
+// Handler returns the http handler serving the metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -3,13 +3,21 @@ package pubsub
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.infratographer.com/x/events"
 	"go.uber.org/zap"
+
+	"go.infratographer.com/loadbalancer-manager-haproxy/internal/metrics"
 )
 
-const defaultNakDelay = 10 * time.Second
+const (
+	defaultNakDelay = 10 * time.Second
+
+	// defaultMaxConcurrentMsgs lets the message handler coalesce bursts of messages
+	defaultMaxConcurrentMsgs = 100
+)
 
 // MsgHandler is a callback function that processes messages delivered to subscribers
 type MsgHandler func(msg events.Message[events.ChangeMessage]) error
@@ -22,6 +30,13 @@ type Subscriber struct {
 	logger                *zap.SugaredLogger
 	connection            events.Connection
 	maxProcessMsgAttempts uint64
+	maxConcurrentMsgs     int
+
+	// started is set once Listen is called
+	started atomic.Bool
+
+	// listeners counts the subscriptions listened to
+	listeners atomic.Int32
 }
 
 // SubscriberOption is a functional option for the Subscriber
@@ -48,12 +63,23 @@ func WithMaxMsgProcessAttempts(max uint64) SubscriberOption {
 	}
 }
 
+// WithMaxConcurrentMsgs sets the maximum number of messages, across all subscriptions, handled at the same time.
+// Handling messages concurrently allows a handler to coalesce them, each message is still acked or naked on its own.
+// With a single message at a time, each message waits for the handler to return before the next one is handled,
+// so the handler can never coalesce them.
+func WithMaxConcurrentMsgs(max int) SubscriberOption {
+	return func(s *Subscriber) {
+		s.maxConcurrentMsgs = max
+	}
+}
+
 // NewSubscriber creates a new Subscriber
 func NewSubscriber(ctx context.Context, connection events.Connection, opts ...SubscriberOption) *Subscriber {
 	s := &Subscriber{
-		ctx:        ctx,
-		logger:     zap.NewNop().Sugar(),
-		connection: connection,
+		ctx:               ctx,
+		logger:            zap.NewNop().Sugar(),
+		connection:        connection,
+		maxConcurrentMsgs: defaultMaxConcurrentMsgs,
 	}
 
 	for _, opt := range opts {
@@ -78,18 +104,24 @@ func (s *Subscriber) Subscribe(topic string) error {
 }
 
 // Listen start listening for messages on registered subjects and calls the registered message handler
-func (s Subscriber) Listen() error {
+func (s *Subscriber) Listen() error {
 	wg := &sync.WaitGroup{}
 
 	if s.msgHandler == nil {
 		return ErrMsgHandlerNotRegistered
 	}
 
+	// limits the messages handled at the same time
+	slots := make(chan struct{}, max(s.maxConcurrentMsgs, 1))
+
+	s.started.Store(true)
+
 	// goroutine for each change channel
 	for _, ch := range s.changeChannels {
 		wg.Add(1)
+		s.listeners.Add(1)
 
-		go s.listen(ch, wg)
+		go s.listen(ch, slots, wg)
 	}
 
 	wg.Wait()
@@ -97,30 +129,71 @@ func (s Subscriber) Listen() error {
 	return nil
 }
 
-// listen listens for messages on a channel and calls the registered message handler
-func (s Subscriber) listen(messages <-chan events.Message[events.ChangeMessage], wg *sync.WaitGroup) {
+// Listening returns true while every subscription is listened to
+func (s *Subscriber) Listening() bool {
+	return len(s.changeChannels) > 0 && int(s.listeners.Load()) == len(s.changeChannels)
+}
+
+// Alive returns false once a subscription is no longer listened to, as its channel closed
+func (s *Subscriber) Alive() bool {
+	return !s.started.Load() || s.Listening()
+}
+
+// listen listens for messages on a channel and handles each of them once a slot is free
+func (s *Subscriber) listen(messages <-chan events.Message[events.ChangeMessage], slots chan struct{}, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer s.listeners.Add(-1)
+
+	inflight := &sync.WaitGroup{}
+	defer inflight.Wait()
 
 	for msg := range messages {
-		slogger := s.logger.With(
-			"event.message.id", msg.ID(),
-			"event.message.topic", msg.Topic(),
-			"event.message.source", msg.Source(),
-			"event.message.timestamp", msg.Timestamp(),
-			"event.message.deliveries", msg.Deliveries(),
-		)
-
-		if err := s.msgHandler(msg); err != nil {
-			if s.maxProcessMsgAttempts != 0 && msg.Deliveries()+1 > s.maxProcessMsgAttempts {
-				slogger.Warnw("terminating event, too many attempts")
-
-				if termErr := msg.Term(); termErr != nil {
-					slogger.Warnw("error occurred while terminating event")
-				}
-			} else if nakErr := msg.Nak(defaultNakDelay); nakErr != nil {
+		slots <- struct{}{}
+
+		inflight.Add(1)
+
+		go func(msg events.Message[events.ChangeMessage]) {
+			defer func() {
+				<-slots
+				inflight.Done()
+			}()
+
+			s.handle(msg)
+		}(msg)
+	}
+}
+
+// handle calls the registered message handler, and acks or naks the message with its result
+func (s *Subscriber) handle(msg events.Message[events.ChangeMessage]) {
+	slogger := s.logger.With(
+		"event.message.id", msg.ID(),
+		"event.message.topic", msg.Topic(),
+		"event.message.source", msg.Source(),
+		"event.message.timestamp", msg.Timestamp(),
+		"event.message.deliveries", msg.Deliveries(),
+	)
+
+	metrics.EventsTotal.WithLabelValues(metrics.EventReceived).Inc()
+
+	if err := s.msgHandler(msg); err != nil {
+		if s.maxProcessMsgAttempts != 0 && msg.Deliveries()+1 > s.maxProcessMsgAttempts {
+			slogger.Warnw("terminating event, too many attempts")
+			metrics.EventsTotal.WithLabelValues(metrics.EventTerminated).Inc()
+
+			if termErr := msg.Term(); termErr != nil {
+				slogger.Warnw("error occurred while terminating event")
+			}
+		} else {
+			metrics.EventsTotal.WithLabelValues(metrics.EventNaked).Inc()
+
+			if nakErr := msg.Nak(defaultNakDelay); nakErr != nil {
 				slogger.Warnw("error occurred while naking", "error", nakErr)
 			}
-		} else if ackErr := msg.Ack(); ackErr != nil {
+		}
+	} else {
+		metrics.EventsTotal.WithLabelValues(metrics.EventAcked).Inc()
+
+		if ackErr := msg.Ack(); ackErr != nil {
 			slogger.Warnw("error occurred while acking", "error", ackErr)
 		}
 	}
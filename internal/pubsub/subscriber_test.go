@@ -0,0 +1,258 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/events"
+)
+
+const (
+	resultAck  = "ack"
+	resultNak  = "nak"
+	resultTerm = "term"
+)
+
+var errHandler = errors.New("handler failed")
+
+// fakeMessage records how it was acknowledged
+type fakeMessage struct {
+	id         string
+	deliveries uint64
+
+	mu     sync.Mutex
+	result string
+}
+
+func (m *fakeMessage) Connection() events.Connection { return nil }
+func (m *fakeMessage) ID() string                    { return m.id }
+func (m *fakeMessage) Topic() string                 { return "changes.create.loadbalancer" }
+func (m *fakeMessage) Message() events.ChangeMessage { return events.ChangeMessage{} }
+func (m *fakeMessage) Ack() error                    { return m.record(resultAck) }
+func (m *fakeMessage) Nak(_ time.Duration) error     { return m.record(resultNak) }
+func (m *fakeMessage) Term() error                   { return m.record(resultTerm) }
+func (m *fakeMessage) Timestamp() time.Time          { return time.Time{} }
+func (m *fakeMessage) Deliveries() uint64            { return m.deliveries }
+func (m *fakeMessage) Error() error                  { return nil }
+func (m *fakeMessage) Source() any                   { return nil }
+
+func (m *fakeMessage) record(result string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.result = result
+
+	return nil
+}
+
+func (m *fakeMessage) acknowledged() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.result
+}
+
+// newTestSubscriber returns a subscriber listening to the returned channels
+func newTestSubscriber(subscriptions int, opts ...SubscriberOption) (*Subscriber, []chan events.Message[events.ChangeMessage]) {
+	s := NewSubscriber(context.Background(), nil, opts...)
+
+	channels := []chan events.Message[events.ChangeMessage]{}
+
+	for i := 0; i < subscriptions; i++ {
+		ch := make(chan events.Message[events.ChangeMessage])
+
+		channels = append(channels, ch)
+		s.changeChannels = append(s.changeChannels, ch)
+	}
+
+	return s, channels
+}
+
+func TestListenAcks(t *testing.T) {
+	tests := []struct {
+		name       string
+		handlerErr error
+		deliveries uint64
+		expected   string
+	}{
+		{"handled message is acked", nil, 0, resultAck},
+		{"failed message is naked", errHandler, 0, resultNak},
+		{"failed message is naked until the last attempt", errHandler, 1, resultNak},
+		{"failed message is terminated after too many attempts", errHandler, 2, resultTerm},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, channels := newTestSubscriber(1,
+				WithMaxMsgProcessAttempts(2),
+				WithMsgHandler(func(msg events.Message[events.ChangeMessage]) error {
+					return tt.handlerErr
+				}),
+			)
+
+			listened := make(chan error, 1)
+
+			go func() {
+				listened <- s.Listen()
+			}()
+
+			msg := &fakeMessage{id: "msg", deliveries: tt.deliveries}
+
+			channels[0] <- msg
+			close(channels[0])
+
+			require.NoError(t, <-listened)
+			assert.Equal(t, tt.expected, msg.acknowledged())
+		})
+	}
+}
+
+func TestListenSlots(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []SubscriberOption
+		expected int32
+	}{
+		{"handles messages one at a time", []SubscriberOption{WithMaxConcurrentMsgs(1)}, 1},
+		{"handles messages concurrently up to the limit", []SubscriberOption{WithMaxConcurrentMsgs(3)}, 3},
+		{"handles one message at least", []SubscriberOption{WithMaxConcurrentMsgs(0)}, 1},
+		{"handles every message by default", nil, 6},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var inflight, peak atomic.Int32
+
+			release := make(chan struct{})
+
+			s, channels := newTestSubscriber(2, append(tt.opts,
+				WithMsgHandler(func(msg events.Message[events.ChangeMessage]) error {
+					n := inflight.Add(1)
+					defer inflight.Add(-1)
+
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+
+					<-release
+
+					return nil
+				}),
+			)...)
+
+			listened := make(chan error, 1)
+
+			go func() {
+				listened <- s.Listen()
+			}()
+
+			messages := []*fakeMessage{}
+
+			for i := 0; i < 6; i++ {
+				messages = append(messages, &fakeMessage{id: "msg"})
+			}
+
+			// publish on both subscriptions, the slots are shared
+			publish := &sync.WaitGroup{}
+
+			for i, ch := range channels {
+				publish.Add(1)
+
+				go func(i int, ch chan events.Message[events.ChangeMessage]) {
+					defer publish.Done()
+					defer close(ch)
+
+					for j := 0; j < 3; j++ {
+						ch <- messages[i*3+j]
+					}
+				}(i, ch)
+			}
+
+			require.Eventually(t, func() bool {
+				return inflight.Load() == tt.expected
+			}, time.Second, time.Millisecond)
+
+			// no more messages are handled while the slots are taken
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, tt.expected, inflight.Load())
+
+			close(release)
+			publish.Wait()
+
+			require.NoError(t, <-listened)
+			assert.Equal(t, tt.expected, peak.Load())
+
+			for _, msg := range messages {
+				assert.Equal(t, resultAck, msg.acknowledged())
+			}
+		})
+	}
+}
+
+func TestListenShutdown(t *testing.T) {
+	t.Run("requires a message handler", func(t *testing.T) {
+		s, _ := newTestSubscriber(1)
+
+		require.ErrorIs(t, s.Listen(), ErrMsgHandlerNotRegistered)
+	})
+
+	t.Run("waits for the messages being handled", func(t *testing.T) {
+		release := make(chan struct{})
+
+		s, channels := newTestSubscriber(2, WithMsgHandler(func(msg events.Message[events.ChangeMessage]) error {
+			<-release
+			return nil
+		}))
+
+		assert.True(t, s.Alive())
+		assert.False(t, s.Listening())
+
+		listened := make(chan error, 1)
+
+		go func() {
+			listened <- s.Listen()
+		}()
+
+		require.Eventually(t, s.Listening, time.Second, time.Millisecond)
+
+		msg := &fakeMessage{id: "msg"}
+
+		channels[1] <- msg
+
+		// a closed subscription is no longer listened to
+		close(channels[0])
+
+		require.Eventually(t, func() bool { return !s.Alive() }, time.Second, time.Millisecond)
+		assert.False(t, s.Listening())
+
+		close(channels[1])
+
+		select {
+		case <-listened:
+			t.Fatal("listen returned while a message was being handled")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+
+		require.NoError(t, <-listened)
+		assert.Equal(t, resultAck, msg.acknowledged())
+	})
+}